@@ -0,0 +1,35 @@
+// Command packetgen generates the Go and TypeScript packet types from internal/signaling/schema/packets.json.
+//
+// It runs with go generate ./internal/signaling.
+package main
+
+import (
+	"flag"
+	"log"
+	"os"
+
+	"github.com/poki/netlib/internal/signaling/schema"
+)
+
+func main() {
+	goOut := flag.String("go", "packets_gen.go", "file to write the Go types to")
+	goPackage := flag.String("package", "signaling", "package of the Go types")
+	tsOut := flag.String("ts", "", "file to write the TypeScript types to")
+	flag.Parse()
+
+	packets := schema.Packets()
+
+	src, err := packets.GenerateGo(*goPackage)
+	if err != nil {
+		log.Fatalf("failed to generate go: %v", err)
+	}
+	if err := os.WriteFile(*goOut, src, 0o644); err != nil {
+		log.Fatalf("failed to write %s: %v", *goOut, err)
+	}
+
+	if *tsOut != "" {
+		if err := os.WriteFile(*tsOut, packets.GenerateTS(), 0o644); err != nil {
+			log.Fatalf("failed to write %s: %v", *tsOut, err)
+		}
+	}
+}
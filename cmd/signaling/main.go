@@ -11,8 +11,12 @@ import (
 
 	"github.com/koenbollen/logging"
 	"github.com/poki/netlib/internal"
+	"github.com/poki/netlib/internal/auth"
 	"github.com/poki/netlib/internal/cloudflare"
+	"github.com/poki/netlib/internal/gameconfig"
 	"github.com/poki/netlib/internal/metrics"
+	"github.com/poki/netlib/internal/moderation"
+	"github.com/poki/netlib/internal/signaling"
 	"github.com/poki/netlib/internal/signaling/stores"
 	"github.com/poki/netlib/internal/util"
 	"github.com/rs/cors"
@@ -45,7 +49,37 @@ func main() {
 
 	go credentialsClient.Run(ctx)
 
-	mux, cleanup := internal.Signaling(ctx, store, credentialsClient)
+	rateLimits, err := signaling.RateLimitsFromEnv()
+	if err != nil {
+		logger.WithOptions(zap.AddStacktrace(zapcore.InvalidLevel)).Error("failed to configure rate limits", zap.Error(err))
+		return
+	}
+
+	games, err := gameconfig.FromEnv()
+	if err != nil {
+		logger.WithOptions(zap.AddStacktrace(zapcore.InvalidLevel)).Error("failed to load game config", zap.Error(err))
+		return
+	}
+
+	identity, err := auth.IdentityFromEnv()
+	if err != nil {
+		logger.WithOptions(zap.AddStacktrace(zapcore.InvalidLevel)).Error("failed to configure identity verification", zap.Error(err))
+		return
+	}
+
+	moderationFilter, err := moderation.FromEnv()
+	if err != nil {
+		logger.WithOptions(zap.AddStacktrace(zapcore.InvalidLevel)).Error("failed to configure moderation", zap.Error(err))
+		return
+	}
+
+	mux, cleanup := internal.Signaling(ctx, store, credentialsClient, signaling.HandlerOptions{
+		RateLimits: rateLimits,
+		Games:      games,
+		Identity:   identity,
+		Moderation: moderationFilter,
+		AdminToken: os.Getenv("ADMIN_TOKEN"),
+	})
 
 	corsHandler := cors.Default()
 	handler := corsHandler.Handler(mux)
@@ -53,9 +87,14 @@ func main() {
 	handler = util.NoLogServedMiddleware(handler)
 	handler = logging.Middleware(handler, logger)
 
-	if metricsURL, ok := os.LookupEnv("METRICS_URL"); ok {
-		client := metrics.NewClient(metricsURL)
-		handler = metrics.Middleware(handler, client)
+	metricsClient, err := metrics.FromEnv(ctx)
+	if err != nil {
+		logger.WithOptions(zap.AddStacktrace(zapcore.InvalidLevel)).Error("failed to setup metrics", zap.Error(err))
+		return
+	}
+	if metricsClient != nil {
+		defer metricsClient.Close() // nolint:errcheck
+		handler = metrics.Middleware(handler, metricsClient)
 	}
 
 	addr := util.Getenv("ADDR", ":8080")
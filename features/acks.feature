@@ -0,0 +1,81 @@
+Feature: Messages to peers that ack are resent after a reconnect
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" opens a websocket
+    And "blue" sends:
+      """
+      {"type": "hello", "game": "5f482b59-ce70-4e0b-847b-dc2d96a54f0b", "capabilities": ["acks"]}
+      """
+    And "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+    And "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "acked"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+    And "yellow" is connected to the signaling server for game "5f482b59-ce70-4e0b-847b-dc2d96a54f0b"
+    And "yellow" sends:
+      """
+      {"type": "join", "rid": "2", "lobby": "acked"}
+      """
+    And "yellow" receives:
+      """
+      {"type": "joined", "rid": "2"}
+      """
+
+
+  Scenario: Messages get a sequence number from the server
+    Then "blue" receives:
+      """
+      {"type": "connect", "id": "{{yellow.id}}", "msgSeq": 1}
+      """
+    And "yellow" receives a "connect" packet without "msgSeq"
+
+    When "yellow" sends:
+      """
+      {"type": "candidate", "source": "{{yellow.id}}", "recipient": "{{blue.id}}", "candidate": {"candidate": "a"}, "msgSeq": 99, "rid": "3", "attempt": 2}
+      """
+    Then "blue" receives:
+      """
+      {"type": "candidate", "source": "{{yellow.id}}", "candidate": {"candidate": "a"}, "msgSeq": 2, "rid": "3", "attempt": 2}
+      """
+
+
+  Scenario: Messages that weren't acked are resent after a reconnect
+    When "blue" receives:
+      """
+      {"type": "connect", "id": "{{yellow.id}}", "msgSeq": 1}
+      """
+    And "blue" sends:
+      """
+      {"type": "ack", "msgSeq": 1}
+      """
+    And "yellow" sends:
+      """
+      {"type": "candidate", "source": "{{yellow.id}}", "recipient": "{{blue.id}}", "candidate": {"candidate": "b"}}
+      """
+    And "blue" receives:
+      """
+      {"type": "candidate", "msgSeq": 2}
+      """
+    And the websocket of "blue" is dropped
+    And "blue2" opens a websocket
+    And "blue2" sends:
+      """
+      {"type": "hello", "game": "5f482b59-ce70-4e0b-847b-dc2d96a54f0b", "id": "{{blue.id}}", "secret": "{{blue.secret}}", "capabilities": ["acks"]}
+      """
+    Then "blue2" receives:
+      """
+      {"type": "welcome", "id": "{{blue.id}}"}
+      """
+    And "blue2" receives:
+      """
+      {"type": "candidate", "source": "{{yellow.id}}", "candidate": {"candidate": "b"}, "msgSeq": 2}
+      """
+    And "blue2" does not receive a "connect" packet
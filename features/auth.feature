@@ -0,0 +1,73 @@
+Feature: Registered games only accept signed peers from their origins
+
+  Background:
+    Given the signaling backend uses this game config:
+      """
+      {
+        "games": {
+          "3a55e9d7-fa6d-4874-826f-cc384611922b": {
+            "signingKey": "game-signing-key",
+            "allowedOrigins": ["https://*.example.com"]
+          }
+        }
+      }
+      """
+    And the "signaling" backend is running
+
+
+  Scenario: A peer with a token signed for the game is welcomed
+    When "blue" opens a websocket from the origin "https://play.example.com"
+    And "blue" says hello for game "3a55e9d7-fa6d-4874-826f-cc384611922b" with a "token" signed with "game-signing-key":
+      """
+      {"sub": "player-1", "game": "3a55e9d7-fa6d-4874-826f-cc384611922b"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "welcome", "userId": "player-1"}
+      """
+
+
+  Scenario: A peer without a token is rejected
+    When "blue" opens a websocket from the origin "https://play.example.com"
+    And "blue" sends:
+      """
+      {"type": "hello", "game": "3a55e9d7-fa6d-4874-826f-cc384611922b"}
+      """
+    Then "blue" receives the error "token-required"
+    And "blue" does not receive a "welcome" packet
+
+
+  Scenario: A peer with a token signed with another key is rejected
+    When "blue" opens a websocket from the origin "https://play.example.com"
+    And "blue" says hello for game "3a55e9d7-fa6d-4874-826f-cc384611922b" with a "token" signed with "another-key":
+      """
+      {"sub": "player-1", "game": "3a55e9d7-fa6d-4874-826f-cc384611922b"}
+      """
+    Then "blue" receives the error "invalid-token"
+
+
+  Scenario: A peer with a token for another game is rejected
+    When "blue" opens a websocket from the origin "https://play.example.com"
+    And "blue" says hello for game "3a55e9d7-fa6d-4874-826f-cc384611922b" with a "token" signed with "game-signing-key":
+      """
+      {"sub": "player-1", "game": "931ed8b6-bf11-4b45-a0a2-a717fb4f70e5"}
+      """
+    Then "blue" receives the error "invalid-token"
+
+
+  Scenario: A peer with an expired token is rejected
+    When "blue" opens a websocket from the origin "https://play.example.com"
+    And "blue" says hello for game "3a55e9d7-fa6d-4874-826f-cc384611922b" with a "token" signed with "game-signing-key":
+      """
+      {"sub": "player-1", "game": "3a55e9d7-fa6d-4874-826f-cc384611922b", "exp": 1000000000}
+      """
+    Then "blue" receives the error "token-expired"
+
+
+  Scenario: A peer from another origin is rejected
+    When "blue" opens a websocket from the origin "https://example.org"
+    And "blue" says hello for game "3a55e9d7-fa6d-4874-826f-cc384611922b" with a "token" signed with "game-signing-key":
+      """
+      {"sub": "player-1", "game": "3a55e9d7-fa6d-4874-826f-cc384611922b"}
+      """
+    Then "blue" receives the error "origin-not-allowed"
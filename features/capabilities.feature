@@ -0,0 +1,71 @@
+Feature: Clients and the server negotiate protocol capabilities
+
+  Background:
+    Given the signaling backend uses this game config:
+      """
+      {
+        "games": {
+          "888c299a-4e4f-40a2-a6d6-bd0b639f16d6": {
+            "minClientVersion": "1.4.0"
+          }
+        }
+      }
+      """
+    And the "signaling" backend is running
+
+
+  Scenario: The welcome packet has the protocol version and capabilities of the server
+    When "blue" opens a websocket
+    And "blue" sends:
+      """
+      {"type": "hello", "game": "64c0cc95-42bc-46db-9d25-32b3da13b6cb"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "welcome", "protocolVersion": 2, "capabilities": ["acks", "lobbyInfo", "replay"]}
+      """
+
+
+  Scenario: Clients with the lobbyInfo capability don't get the old lobby field
+    When "blue" opens a websocket
+    And "blue" sends:
+      """
+      {"type": "hello", "game": "64c0cc95-42bc-46db-9d25-32b3da13b6cb", "capabilities": ["lobbyInfo", "unknown"]}
+      """
+    And "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+    And "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "modern"}
+      """
+    Then "blue" receives a "joined" packet without "lobby"
+
+    When "yellow" is connected to the signaling server for game "64c0cc95-42bc-46db-9d25-32b3da13b6cb"
+    And "yellow" sends:
+      """
+      {"type": "join", "rid": "2", "lobby": "modern"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "joined", "rid": "2", "lobby": "modern", "lobbyInfo": {"code": "modern"}}
+      """
+
+
+  Scenario: Games can require a minimum client version
+    When "blue" opens a websocket
+    And "blue" sends:
+      """
+      {"type": "hello", "game": "888c299a-4e4f-40a2-a6d6-bd0b639f16d6", "version": "1.3.9"}
+      """
+    Then "blue" receives the error "upgrade-required"
+
+    When "blue" sends:
+      """
+      {"type": "hello", "game": "888c299a-4e4f-40a2-a6d6-bd0b639f16d6", "version": "1.4.0"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "welcome"}
+      """
@@ -0,0 +1,44 @@
+Feature: Games can compress their websocket connections
+
+  Background:
+    Given the signaling backend uses this game config:
+      """
+      {
+        "games": {
+          "f5a77ff8-7ed0-4f29-9278-9a9f618fd182": {
+            "compression": "context-takeover",
+            "compressionMinClientVersion": "1.2.0"
+          }
+        }
+      }
+      """
+    And the "signaling" backend is running
+
+
+  Scenario: Clients of the game with a new enough version get compression
+    When "blue" opens a websocket with the url parameters "game=f5a77ff8-7ed0-4f29-9278-9a9f618fd182&version=1.3.0"
+    Then the websocket of "blue" is compressed
+
+    When "blue" sends:
+      """
+      {"type": "hello", "game": "f5a77ff8-7ed0-4f29-9278-9a9f618fd182", "version": "1.3.0"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+
+
+  Scenario: Older clients don't get compression
+    When "blue" opens a websocket with the url parameters "game=f5a77ff8-7ed0-4f29-9278-9a9f618fd182&version=1.1.9"
+    Then the websocket of "blue" is not compressed
+
+
+  Scenario: Other games don't get compression
+    When "blue" opens a websocket with the url parameters "game=62226c20-37d5-4a31-9f75-ca3c1cefe0ec&version=1.3.0"
+    Then the websocket of "blue" is not compressed
+
+
+  Scenario: Clients that don't say which game they are for don't get compression
+    When "blue" opens a websocket
+    Then the websocket of "blue" is not compressed
@@ -0,0 +1,79 @@
+Feature: Games can validate the customData of lobbies with a JSON Schema
+
+  Background:
+    Given the signaling backend uses this game config:
+      """
+      {
+        "games": {
+          "9e3b7d2f-6a4c-4f18-8b5e-2d9a6c4f1e73": {
+            "customDataSchema": {
+              "type": "object",
+              "properties": {
+                "map": {"enum": ["forest", "desert"]},
+                "rounds": {"type": "integer", "minimum": 1}
+              }
+            }
+          }
+        }
+      }
+      """
+    And the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "9e3b7d2f-6a4c-4f18-8b5e-2d9a6c4f1e73"
+
+
+  Scenario: A lobby can't be created with customData that doesn't match the schema
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "customData": {"map": "moon"}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "invalid-custom-data", "error": {"path": "customData.map"}}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "2", "customData": {"map": "forest", "rounds": 3}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "2", "lobbyInfo": {"customData": {"map": "forest", "rounds": 3}}}
+      """
+
+
+  Scenario: Updates of the customData have to match the schema
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "customData": {"map": "forest"}}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "2", "customData": {"map": "forest", "rounds": 0}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "2", "code": "invalid-custom-data", "error": {"path": "customData.rounds"}}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "3", "setCustomData": {"map": "moon"}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "3", "code": "invalid-custom-data", "error": {"path": "customData.map"}}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "4", "setCustomData": {"map": "desert"}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "lobbyUpdated", "rid": "4", "lobbyInfo": {"customData": {"map": "desert"}}}
+      """
@@ -0,0 +1,76 @@
+Feature: The customData of a lobby can be updated partially
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "ff0efe68-29c6-4303-87d4-ba9ee0199ac2"
+    And "yellow" is connected to the signaling server for game "ff0efe68-29c6-4303-87d4-ba9ee0199ac2"
+
+
+  Scenario: customData can be patched and keys can be set and unset
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "patched", "canUpdateBy": "anyone", "customData": {"map": "forest", "teams": {"red": 1, "blue": 2}, "countdown": 10}}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"version": 1}}
+      """
+    And "yellow" sends:
+      """
+      {"type": "join", "rid": "2", "lobby": "patched"}
+      """
+    And "yellow" receives:
+      """
+      {"type": "joined", "rid": "2"}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "3", "customDataPatch": {"teams": {"blue": 3}, "map": null}, "unsetCustomData": ["countdown"]}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "lobbyUpdated", "rid": "3", "lobbyInfo": {"version": 2, "customData": {"teams": {"red": 1, "blue": 3}, "map": null, "countdown": null}}}
+      """
+    And "blue" receives:
+      """
+      {"type": "lobbyUpdated", "lobbyInfo": {"version": 2, "customData": {"teams": {"red": 1, "blue": 3}}}}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "4", "setCustomData": {"map": "desert"}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "lobbyUpdated", "rid": "4", "lobbyInfo": {"version": 3, "customData": {"map": "desert", "teams": {"red": 1, "blue": 3}}}}
+      """
+
+
+  Scenario: An update with an outdated version is rejected
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "customData": {"round": 1}}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"version": 1}}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "2", "expectedVersion": 1, "setCustomData": {"round": 2}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "lobbyUpdated", "rid": "2", "lobbyInfo": {"version": 2, "customData": {"round": 2}}}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "3", "expectedVersion": 1, "setCustomData": {"round": 3}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "3", "code": "version-conflict"}
+      """
@@ -0,0 +1,104 @@
+Feature: Errors have a code and the request ID of the packet that caused them
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" opens a websocket
+
+
+  Scenario: Packets that need a peer are rejected before hello
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "peer-not-connected"}
+      """
+    And the websocket of "blue" is still open
+
+
+  Scenario: Packets that can't be handled in the current state are rejected
+    When "blue" sends:
+      """
+      {"type": "hello", "game": "4f90f090-977d-4bfc-89b4-a1bc3eea1bf3"}
+      """
+    And "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+    And "blue" sends:
+      """
+      {"type": "hello", "game": "4f90f090-977d-4bfc-89b4-a1bc3eea1bf3"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "code": "already-introduced"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "leave", "rid": "1"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "not-in-lobby"}
+      """
+    And the websocket of "blue" is still open
+
+
+  Scenario: Forwarding a packet for another peer closes the connection
+    Given "yellow" is connected to the signaling server for game "4f90f090-977d-4bfc-89b4-a1bc3eea1bf3"
+    When "blue" sends:
+      """
+      {"type": "hello", "game": "4f90f090-977d-4bfc-89b4-a1bc3eea1bf3"}
+      """
+    And "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+    And "blue" sends:
+      """
+      {"type": "candidate", "source": "{{yellow.id}}", "recipient": "{{yellow.id}}", "candidate": null}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "code": "invalid-source"}
+      """
+    And the websocket of "blue" is closed
+
+
+  Scenario: Updating a lobby without permission has a code
+    Given "yellow" is connected to the signaling server for game "4f90f090-977d-4bfc-89b4-a1bc3eea1bf3"
+    When "blue" sends:
+      """
+      {"type": "hello", "game": "4f90f090-977d-4bfc-89b4-a1bc3eea1bf3"}
+      """
+    And "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+    And "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "creator-only"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+    And "yellow" sends:
+      """
+      {"type": "join", "rid": "2", "lobby": "creator-only"}
+      """
+    And "yellow" receives:
+      """
+      {"type": "joined", "rid": "2"}
+      """
+    And "yellow" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "3", "public": true}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "error", "rid": "3", "code": "update-not-allowed"}
+      """
+    And the websocket of "yellow" is still open
@@ -0,0 +1,55 @@
+Feature: Lobbies can expire
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "e2a7c4f1-6b3d-4e8a-9c5f-1d7b3e9a2c64"
+
+
+  Scenario: A lobby is closed when its ttl passed
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "short-lived", "ttl": 1}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"code": "short-lived"}}
+      """
+    And "blue" receives:
+      """
+      {"type": "lobbyClosed", "lobby": "short-lived", "reason": "expired"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "2"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "2"}
+      """
+
+
+  Scenario: A lobby is closed when it's idle for too long
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "idle-lobby", "idleTimeout": 1}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"idleTimeout": 1}}
+      """
+    And "blue" receives:
+      """
+      {"type": "lobbyClosed", "lobby": "idle-lobby", "reason": "idle"}
+      """
+
+
+  Scenario: A lobby can't have a negative ttl
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "ttl": -1}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "invalid-ttl"}
+      """
@@ -0,0 +1,88 @@
+Feature: Games can have their own limits
+
+  Background:
+    Given the signaling backend uses this game config:
+      """
+      {
+        "games": {
+          "931ed8b6-bf11-4b45-a0a2-a717fb4f70e5": {
+            "maxPlayers": 2,
+            "defaultMaxPlayers": 2,
+            "maxLobbies": 1,
+            "publicListing": false
+          }
+        }
+      }
+      """
+    And the "signaling" backend is running
+
+
+  Scenario: Lobbies can't have more players than the game allows
+    Given "blue" is connected to the signaling server for game "931ed8b6-bf11-4b45-a0a2-a717fb4f70e5"
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "maxPlayers": 3}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "max-players-exceeded"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "2"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "2", "lobbyInfo": {"maxPlayers": 2}}
+      """
+
+
+  Scenario: A game can't have more lobbies than the game allows
+    Given "blue" is connected to the signaling server for game "931ed8b6-bf11-4b45-a0a2-a717fb4f70e5"
+    And "yellow" is connected to the signaling server for game "931ed8b6-bf11-4b45-a0a2-a717fb4f70e5"
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "create", "rid": "2"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "error", "rid": "2", "code": "too-many-lobbies"}
+      """
+
+
+  Scenario: Listing lobbies can be disabled
+    Given "blue" is connected to the signaling server for game "931ed8b6-bf11-4b45-a0a2-a717fb4f70e5"
+
+    When "blue" sends:
+      """
+      {"type": "list", "rid": "1"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "public-listing-disabled"}
+      """
+
+
+  Scenario: Games that aren't configured use the defaults
+    Given "blue" is connected to the signaling server for game "37dca4ab-cd31-4a8a-bfc8-29ad4589e6ea"
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "maxPlayers": 3}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"maxPlayers": 3}}
+      """
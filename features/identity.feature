@@ -0,0 +1,58 @@
+Feature: Peers can share their verified identity with their lobby
+
+  Background:
+    Given the signaling backend has the environment variable "IDENTITY_HMAC_KEY" set to "identity-key"
+    And the "signaling" backend is running
+
+
+  Scenario: Lobby members see the identity of the other peers
+    When "blue" opens a websocket
+    And "blue" says hello for game "1f0b3a8e-5c34-4e7a-9d2b-6a51c2e8f407" with a "identityToken" signed with "identity-key":
+      """
+      {"sub": "user-42", "name": "Blue"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "welcome", "userId": "user-42", "displayName": "Blue"}
+      """
+
+    When "yellow" opens a websocket
+    And "yellow" says hello for game "1f0b3a8e-5c34-4e7a-9d2b-6a51c2e8f407" with a "identityToken" signed with "identity-key":
+      """
+      {"sub": "user-43", "nickname": "Yellow"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "welcome", "userId": "user-43", "displayName": "Yellow"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "create", "code": "identity-lobby"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined"}
+      """
+    And "yellow" sends:
+      """
+      {"type": "join", "lobby": "identity-lobby"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "joined", "lobbyInfo": {"peerInfo": {"{{blue.id}}": {"userId": "user-42", "displayName": "Blue"}}}}
+      """
+    And "blue" receives:
+      """
+      {"type": "connect", "id": "{{yellow.id}}", "peer": {"userId": "user-43", "displayName": "Yellow"}}
+      """
+
+
+  Scenario: A peer with an invalid identity token is rejected
+    When "blue" opens a websocket
+    And "blue" says hello for game "1f0b3a8e-5c34-4e7a-9d2b-6a51c2e8f407" with a "identityToken" signed with "another-key":
+      """
+      {"sub": "user-42"}
+      """
+    Then "blue" receives the error "invalid-identity-token"
+    And "blue" does not receive a "welcome" packet
@@ -0,0 +1,59 @@
+Feature: Invalid packets are rejected without closing the connection
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" opens a websocket
+
+
+  Scenario: A property with the wrong type is rejected
+    When "blue" sends:
+      """
+      {"type": "hello", "game": 42}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "code": "invalid-packet", "error": {"packet": "hello", "field": "game"}}
+      """
+    And the websocket of "blue" is still open
+
+    When "blue" sends:
+      """
+      {"type": "hello", "game": "ec9a3c87-61f6-4015-b307-3298ae1556ad"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "maxPlayers": "four"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "invalid-packet", "error": {"packet": "create", "field": "maxPlayers"}}
+      """
+
+
+  Scenario: Packets have to be objects
+    When "blue" sends:
+      """
+      ["hello", "ec9a3c87-61f6-4015-b307-3298ae1556ad"]
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "code": "invalid-packet"}
+      """
+    And the websocket of "blue" is still open
+
+
+  Scenario: Unknown packet types are rejected
+    When "blue" sends:
+      """
+      {"type": "teleport", "rid": "1"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "unknown-packet-type"}
+      """
+    And the websocket of "blue" is still open
@@ -0,0 +1,113 @@
+Feature: Lobbies can be created with other code formats and vanity codes
+
+  Background:
+    Given the signaling backend uses this game config:
+      """
+      {
+        "games": {
+          "f6c1a9d3-4e2b-4b7f-8a6c-9d3e1f5b7a28": {
+            "codeFormats": ["short"],
+            "customCodes": false
+          }
+        }
+      }
+      """
+    And the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "2b9e5d1c-7f3a-4c8e-a1d6-5e7c9b3f2a41"
+    And "yellow" is connected to the signaling server for game "2b9e5d1c-7f3a-4c8e-a1d6-5e7c9b3f2a41"
+
+
+  Scenario: A lobby gets a numeric code
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "codeFormat": "numeric"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+    And the lobby code of "blue" matches "[0-9]{6}"
+
+
+  Scenario: A lobby gets a code of words with a number of digits
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "codeFormat": "words", "codeLength": 3}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+    And the lobby code of "blue" matches "[a-z]+-[a-z]+-[0-9]{3}"
+
+
+  Scenario: A code length out of range is rejected
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "codeFormat": "short", "codeLength": 12}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "invalid-code-length"}
+      """
+
+
+  Scenario: A lobby can be created with a vanity code
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "friday-night"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"code": "friday-night"}}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "create", "rid": "2", "code": "friday-night"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "error", "rid": "2", "code": "lobby-exists"}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "create", "rid": "3", "code": "friday night!"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "error", "rid": "3", "code": "invalid-lobby-code"}
+      """
+
+
+  Scenario: Games can restrict the code formats and disable vanity codes
+    Given "green" is connected to the signaling server for game "f6c1a9d3-4e2b-4b7f-8a6c-9d3e1f5b7a28"
+
+    When "green" sends:
+      """
+      {"type": "create", "rid": "1", "codeFormat": "numeric"}
+      """
+    Then "green" receives:
+      """
+      {"type": "error", "rid": "1", "code": "code-format-not-allowed"}
+      """
+
+    When "green" sends:
+      """
+      {"type": "create", "rid": "2", "code": "friday-night"}
+      """
+    Then "green" receives:
+      """
+      {"type": "error", "rid": "2", "code": "custom-codes-disabled"}
+      """
+
+    When "green" sends:
+      """
+      {"type": "create", "rid": "3", "codeFormat": "short"}
+      """
+    Then "green" receives:
+      """
+      {"type": "joined", "rid": "3"}
+      """
+    And the lobby code of "green" matches "[0-9A-Z]{4}"
@@ -0,0 +1,75 @@
+Feature: Lobby codes and customData are moderated
+
+  Background:
+    Given the signaling backend uses this game config:
+      """
+      {
+        "games": {
+          "c8d2f7a4-1b6e-4d93-9e5a-7f1c3b8d4e62": {
+            "moderateCustomData": true
+          }
+        }
+      }
+      """
+
+
+  Scenario: Lobby codes with profanity are rejected
+    Given the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "4a7e3c9b-5d2f-4e81-b6a3-8c1d9f2e7b54"
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "5h1t-lobby"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "lobby-code-not-allowed"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "2", "code": "Scunthorpe"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "2", "lobbyInfo": {"code": "Scunthorpe"}}
+      """
+
+
+  Scenario: customData with profanity is rejected for games that moderate it
+    Given the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "c8d2f7a4-1b6e-4d93-9e5a-7f1c3b8d4e62"
+    And "yellow" is connected to the signaling server for game "4a7e3c9b-5d2f-4e81-b6a3-8c1d9f2e7b54"
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "customData": {"players": ["alice", {"name": "what the fucking"}]}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "content-not-allowed", "message": "customData.players[1].name contains blocked content"}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "create", "rid": "2", "customData": {"players": ["alice", {"name": "what the fucking"}]}}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "joined", "rid": "2"}
+      """
+
+
+  Scenario: Moderation can be disabled
+    Given the signaling backend has the environment variable "MODERATION_DISABLED" set to "true"
+    And the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "4a7e3c9b-5d2f-4e81-b6a3-8c1d9f2e7b54"
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "5h1t-lobby"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"code": "5h1t-lobby"}}
+      """
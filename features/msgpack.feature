@@ -0,0 +1,43 @@
+Feature: Packets can be encoded with MessagePack
+
+  Background:
+    Given the "signaling" backend is running
+
+
+  Scenario: Clients that offer msgpack get binary packets
+    When "blue" opens a websocket with the subprotocols "msgpack, json"
+    Then the websocket of "blue" uses the subprotocol "msgpack"
+
+    When "blue" sends:
+      """
+      {"type": "hello", "game": "0cd78a69-f86a-4522-ba0d-28311b031842"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "customData": {"rounds": 3, "teams": ["red", "blue"]}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"customData": {"rounds": 3, "teams": ["red", "blue"]}}}
+      """
+    And "blue" only received binary packets
+
+
+  Scenario: Clients that only offer json get text packets
+    When "blue" opens a websocket with the subprotocols "json"
+    Then the websocket of "blue" uses the subprotocol "json"
+
+    When "blue" sends:
+      """
+      {"type": "hello", "game": "0cd78a69-f86a-4522-ba0d-28311b031842"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+    And "blue" only received text packets
@@ -0,0 +1,72 @@
+Feature: Peers can share public data with their lobby
+
+  Background:
+    Given the signaling backend uses this game config:
+      """
+      {
+        "default": {
+          "peerCustomDataMaxBytes": 64
+        }
+      }
+      """
+    And the "signaling" backend is running
+
+
+  Scenario: Lobby members see and receive updates of the data of other peers
+    When "blue" opens a websocket
+    And "blue" sends:
+      """
+      {"type": "hello", "game": "0c3f6d2a-8b1e-4f5c-a7d9-2e4b6c8a1f30", "peerCustomData": {"name": "Blue"}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+    And "yellow" is connected to the signaling server for game "0c3f6d2a-8b1e-4f5c-a7d9-2e4b6c8a1f30"
+
+    When "blue" sends:
+      """
+      {"type": "create", "code": "peer-data"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined"}
+      """
+    And "yellow" sends:
+      """
+      {"type": "join", "lobby": "peer-data", "peerCustomData": {"name": "Yellow"}}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "joined", "lobbyInfo": {"peerInfo": {"{{blue.id}}": {"customData": {"name": "Blue"}}}}}
+      """
+    And "blue" receives:
+      """
+      {"type": "connect", "id": "{{yellow.id}}", "peer": {"customData": {"name": "Yellow"}}}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "peerUpdate", "rid": "1", "customData": {"name": "Yellow", "ready": true}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "peerUpdated", "peer": {"id": "{{yellow.id}}", "customData": {"name": "Yellow", "ready": true}}}
+      """
+    And "yellow" receives:
+      """
+      {"type": "peerUpdated", "rid": "1", "peer": {"id": "{{yellow.id}}"}}
+      """
+
+
+  Scenario: The data of a peer can't be larger than the game allows
+    Given "blue" is connected to the signaling server for game "0c3f6d2a-8b1e-4f5c-a7d9-2e4b6c8a1f30"
+
+    When "blue" sends:
+      """
+      {"type": "peerUpdate", "rid": "1", "customData": {"bio": "this text is too long to fit in the sixty-four bytes the game allows"}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "custom-data-too-large"}
+      """
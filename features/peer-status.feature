@@ -0,0 +1,45 @@
+Feature: Lobbies are told when a peer is reconnecting
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "b4492247-fd98-4d67-a70a-61c107ca4f6d"
+    And "yellow" is connected to the signaling server for game "b4492247-fd98-4d67-a70a-61c107ca4f6d"
+    And "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "status"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+    And "yellow" sends:
+      """
+      {"type": "join", "rid": "2", "lobby": "status"}
+      """
+    And "yellow" receives:
+      """
+      {"type": "joined", "rid": "2"}
+      """
+
+
+  Scenario: A peer that lost its connection is reconnecting until it's back
+    When the websocket of "yellow" is dropped
+    Then "blue" receives:
+      """
+      {"type": "peerStatus", "id": "{{yellow.id}}", "status": "reconnecting"}
+      """
+    And "blue" does not receive a "disconnect" packet
+
+    When "yellow2" opens a websocket
+    And "yellow2" sends:
+      """
+      {"type": "hello", "game": "b4492247-fd98-4d67-a70a-61c107ca4f6d", "id": "{{yellow.id}}", "secret": "{{yellow.secret}}"}
+      """
+    Then "yellow2" receives:
+      """
+      {"type": "welcome", "id": "{{yellow.id}}"}
+      """
+    And "blue" receives:
+      """
+      {"type": "peerStatus", "id": "{{yellow.id}}", "status": "online"}
+      """
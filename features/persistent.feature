@@ -0,0 +1,139 @@
+Feature: Games can have persistent lobbies
+
+  Background:
+    Given the signaling backend uses this game config:
+      """
+      {
+        "games": {
+          "8b4f1e6a-2d7c-4a93-b5e8-3c9f7a1d6e25": {
+            "lobbyCleanInterval": "1s",
+            "lobbyCleanThreshold": "1s",
+            "persistentLobbies": [
+              {"code": "main-hall", "public": true, "customData": {"name": "Main Hall"}}
+            ]
+          }
+        }
+      }
+      """
+    And the signaling backend has the environment variable "ADMIN_TOKEN" set to "s3cret"
+    And the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "8b4f1e6a-2d7c-4a93-b5e8-3c9f7a1d6e25"
+    And "yellow" is connected to the signaling server for game "8b4f1e6a-2d7c-4a93-b5e8-3c9f7a1d6e25"
+
+
+  Scenario: A persistent lobby exists without being created
+    When "blue" sends:
+      """
+      {"type": "join", "rid": "1", "lobby": "main-hall"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"code": "main-hall", "persistent": true, "public": true, "customData": {"name": "Main Hall"}}}
+      """
+
+
+  Scenario: A persistent lobby isn't cleaned up when it's empty
+    When "blue" sends:
+      """
+      {"type": "join", "rid": "1", "lobby": "main-hall"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+    And "blue" sends:
+      """
+      {"type": "leave", "rid": "2"}
+      """
+    And "blue" receives:
+      """
+      {"type": "left", "rid": "2"}
+      """
+    And "blue" sends:
+      """
+      {"type": "create", "rid": "3", "code": "temporary"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "3"}
+      """
+    And "blue" sends:
+      """
+      {"type": "leave", "rid": "4"}
+      """
+    And "blue" receives:
+      """
+      {"type": "left", "rid": "4"}
+      """
+
+    When 3 seconds pass
+    And "yellow" sends:
+      """
+      {"type": "join", "rid": "5", "lobby": "temporary"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "error", "rid": "5", "code": "lobby-not-found"}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "join", "rid": "6", "lobby": "main-hall"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "joined", "rid": "6", "lobbyInfo": {"code": "main-hall"}}
+      """
+
+
+  Scenario: The code of a persistent lobby can't be used for a new lobby
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "main-hall"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "lobby-code-reserved"}
+      """
+
+
+  Scenario: Peers can't update a persistent lobby by default
+    When "blue" sends:
+      """
+      {"type": "join", "rid": "1", "lobby": "main-hall"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+    And "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "2", "public": false}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "2", "code": "update-not-allowed"}
+      """
+
+
+  Scenario: Peers can't update a lobby created with the admin API
+    When the admin API creates the persistent lobby "admin-room" for game "8b4f1e6a-2d7c-4a93-b5e8-3c9f7a1d6e25" with the token "s3cret":
+      """
+      {"public": true}
+      """
+    And "blue" sends:
+      """
+      {"type": "join", "rid": "1", "lobby": "admin-room"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"code": "admin-room", "persistent": true, "canUpdateBy": "none"}}
+      """
+    And "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "2", "customData": {"name": "Admin Room"}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "2", "code": "update-not-allowed"}
+      """
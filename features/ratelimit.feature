@@ -0,0 +1,57 @@
+Feature: Packets are rate limited per peer and per IP
+
+  Scenario: A peer that sends too many packets gets rate limited
+    Given the signaling backend has the environment variable "RATE_LIMIT_PEER" set to "list=0.01:2"
+    And the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "770bc0f0-2d0a-42f2-851c-5801ebfac848"
+
+    When "blue" sends:
+      """
+      {"type": "list", "rid": "1"}
+      """
+    And "blue" sends:
+      """
+      {"type": "list", "rid": "2"}
+      """
+    And "blue" sends:
+      """
+      {"type": "list", "rid": "3"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "lobbies", "rid": "2"}
+      """
+    And "blue" receives:
+      """
+      {"type": "error", "rid": "3", "code": "rate-limited"}
+      """
+    And the websocket of "blue" is still open
+
+
+  Scenario: Peers on the same IP share the IP limit
+    Given the signaling backend has the environment variable "RATE_LIMIT_IP" set to "list=0.01:2"
+    And the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "770bc0f0-2d0a-42f2-851c-5801ebfac848"
+    And "yellow" is connected to the signaling server for game "770bc0f0-2d0a-42f2-851c-5801ebfac848"
+
+    When "blue" sends:
+      """
+      {"type": "list", "rid": "1"}
+      """
+    And "blue" sends:
+      """
+      {"type": "list", "rid": "2"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "lobbies", "rid": "2"}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "list", "rid": "3"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "error", "rid": "3", "code": "rate-limited"}
+      """
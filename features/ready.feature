@@ -0,0 +1,147 @@
+Feature: Lobbies have a state and a ready-check
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "5d8e2b7c-3a9f-4c61-b0e4-7f2a9c3d5e18"
+    And "yellow" is connected to the signaling server for game "5d8e2b7c-3a9f-4c61-b0e4-7f2a9c3d5e18"
+
+
+  Scenario: Everybody is notified when all peers are ready
+    When "blue" sends:
+      """
+      {"type": "create", "code": "ready-check"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "lobbyInfo": {"state": "waiting"}}
+      """
+    And "yellow" sends:
+      """
+      {"type": "join", "lobby": "ready-check"}
+      """
+    And "yellow" receives:
+      """
+      {"type": "joined"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "ready", "rid": "1", "ready": true}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "readyUpdated", "id": "{{blue.id}}", "ready": true, "readyPeers": ["{{blue.id}}"]}
+      """
+    And "blue" does not receive a "allReady" packet
+
+    When "yellow" sends:
+      """
+      {"type": "ready", "rid": "2", "ready": true}
+      """
+    Then "blue" receives:
+      """
+      {"type": "readyUpdated", "id": "{{yellow.id}}", "ready": true}
+      """
+    And "blue" receives:
+      """
+      {"type": "allReady"}
+      """
+    And "yellow" receives:
+      """
+      {"type": "allReady"}
+      """
+
+
+  Scenario: Changing the state of a lobby resets the ready flags
+    When "blue" sends:
+      """
+      {"type": "create", "code": "ready-reset"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined"}
+      """
+    And "blue" sends:
+      """
+      {"type": "ready", "rid": "1", "ready": true}
+      """
+    And "blue" receives:
+      """
+      {"type": "readyUpdated", "rid": "1", "ready": true}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "2", "state": "starting"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "lobbyUpdated", "rid": "2", "lobbyInfo": {"state": "starting"}}
+      """
+    And "blue" receives a "readyUpdated" packet without "id"
+
+
+  Scenario: Lobbies can only change to the next states
+    When "blue" sends:
+      """
+      {"type": "create", "code": "state-machine"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "1", "state": "finished"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "invalid-state-transition"}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "2", "state": "playing"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "2", "code": "invalid-lobby-state"}
+      """
+
+
+  Scenario: Lobbies that are in-game aren't listed
+    When "blue" sends:
+      """
+      {"type": "create", "code": "in-game", "public": true}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined"}
+      """
+    And "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "1", "state": "in-game"}
+      """
+    And "blue" receives:
+      """
+      {"type": "lobbyUpdated", "rid": "1", "lobbyInfo": {"state": "in-game"}}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "list", "rid": "2"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "lobbies", "rid": "2", "lobbies": []}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "list", "rid": "3", "includeInGame": true}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "lobbies", "rid": "3", "lobbies": [{"code": "in-game", "state": "in-game"}]}
+      """
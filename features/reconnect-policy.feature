@@ -0,0 +1,79 @@
+Feature: Games and lobbies decide how long peers can reconnect
+
+  Background:
+    Given the signaling backend uses this game config:
+      """
+      {
+        "games": {
+          "647bba82-a38e-4818-aafe-ad7812b8b828": {
+            "pingInterval": "1s",
+            "activeUpdateInterval": "1s",
+            "disconnectThreshold": "3s"
+          }
+        }
+      }
+      """
+    And the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "647bba82-a38e-4818-aafe-ad7812b8b828"
+    And "yellow" is connected to the signaling server for game "647bba82-a38e-4818-aafe-ad7812b8b828"
+
+
+  Scenario: A peer that lost its connection is removed after the disconnectThreshold of its game
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "held"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"reconnectPolicy": "hold"}}
+      """
+    And "yellow" sends:
+      """
+      {"type": "join", "rid": "2", "lobby": "held"}
+      """
+    And "yellow" receives:
+      """
+      {"type": "joined", "rid": "2"}
+      """
+    And the websocket of "yellow" is dropped
+    Then "blue" does not receive a "disconnect" packet
+    And "blue" receives:
+      """
+      {"type": "disconnect", "id": "{{yellow.id}}"}
+      """
+
+
+  Scenario: Lobbies with the free reconnect policy remove peers right away
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "freed", "reconnectPolicy": "free"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1", "lobbyInfo": {"reconnectPolicy": "free"}}
+      """
+    And "yellow" sends:
+      """
+      {"type": "join", "rid": "2", "lobby": "freed"}
+      """
+    And "yellow" receives:
+      """
+      {"type": "joined", "rid": "2"}
+      """
+    And the websocket of "yellow" is dropped
+    Then "blue" receives:
+      """
+      {"type": "disconnect", "id": "{{yellow.id}}"}
+      """
+    And "blue" does not receive a "peerStatus" packet
+
+
+  Scenario: An unknown reconnect policy is rejected
+    When "blue" sends:
+      """
+      {"type": "create", "rid": "1", "reconnectPolicy": "forever"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "1", "code": "invalid-reconnect-policy"}
+      """
@@ -0,0 +1,74 @@
+Feature: Peers can replay the events of their lobby
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" opens a websocket
+    And "blue" sends:
+      """
+      {"type": "hello", "game": "4e95ebb7-3c50-4d64-a785-397b9608a349", "capabilities": ["replay"]}
+      """
+    And "blue" receives:
+      """
+      {"type": "welcome"}
+      """
+    And "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "replayable"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+
+
+  Scenario: Lobby events have a sequence number and can be replayed
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "2", "setCustomData": {"round": 1}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "lobbyUpdated", "rid": "2", "seq": 1}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "lobbyUpdate", "rid": "3", "setCustomData": {"round": 2}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "lobbyUpdated", "rid": "3", "seq": 2}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "replay", "rid": "4", "since": 1}
+      """
+    Then "blue" receives:
+      """
+      {"type": "lobbyUpdated", "rid": "3", "seq": 2, "lobbyInfo": {"customData": {"round": 2}}}
+      """
+    And "blue" receives:
+      """
+      {"type": "replayed", "rid": "4", "lobby": "replayable", "seq": 2, "complete": true}
+      """
+    And "blue" does not receive a "lobbyUpdated" packet
+
+
+  Scenario: Replaying requires a lobby
+    When "blue" sends:
+      """
+      {"type": "leave", "rid": "2"}
+      """
+    And "blue" receives:
+      """
+      {"type": "left", "rid": "2"}
+      """
+    And "blue" sends:
+      """
+      {"type": "replay", "rid": "3", "since": 0}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "3", "code": "not-in-lobby"}
+      """
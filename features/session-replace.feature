@@ -0,0 +1,47 @@
+Feature: A peer only has one connection
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "9cb5ac6b-6dcf-4511-b71a-5a7f5eb5cb7d"
+    And "yellow" is connected to the signaling server for game "9cb5ac6b-6dcf-4511-b71a-5a7f5eb5cb7d"
+    And "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "single"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+    And "yellow" sends:
+      """
+      {"type": "join", "rid": "2", "lobby": "single"}
+      """
+    And "yellow" receives:
+      """
+      {"type": "joined", "rid": "2"}
+      """
+
+
+  Scenario: Reconnecting on a new connection closes the old one
+    When "blue2" opens a websocket
+    And "blue2" sends:
+      """
+      {"type": "hello", "game": "9cb5ac6b-6dcf-4511-b71a-5a7f5eb5cb7d", "id": "{{blue.id}}", "secret": "{{blue.secret}}"}
+      """
+    Then "blue2" receives:
+      """
+      {"type": "welcome", "id": "{{blue.id}}"}
+      """
+    And "blue" receives the error "session-replaced"
+    And the websocket of "blue" is closed
+    And the websocket of "blue2" is still open
+    And "yellow" does not receive a "disconnect" packet
+
+    When "yellow" sends:
+      """
+      {"type": "candidate", "source": "{{yellow.id}}", "recipient": "{{blue.id}}", "candidate": {"candidate": "c"}}
+      """
+    Then "blue2" receives:
+      """
+      {"type": "candidate", "source": "{{yellow.id}}", "candidate": {"candidate": "c"}}
+      """
@@ -0,0 +1,61 @@
+Feature: A peer can be taken over by another connection with a transfer token
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "9ae9f671-2714-4774-92c5-d32218c71e86"
+    And "blue" sends:
+      """
+      {"type": "createTransferToken", "rid": "1"}
+      """
+    And "blue" receives:
+      """
+      {"type": "transferToken", "rid": "1"}
+      """
+
+
+  Scenario: A transfer token takes over the peer and closes the old connection
+    When "blue2" opens a websocket
+    And "blue2" sends:
+      """
+      {"type": "hello", "game": "9ae9f671-2714-4774-92c5-d32218c71e86", "transferToken": "{{blue.transferToken}}"}
+      """
+    Then "blue2" receives:
+      """
+      {"type": "welcome", "id": "{{blue.id}}"}
+      """
+    And "blue" receives the error "session-transferred"
+    And the websocket of "blue" is closed
+    And the websocket of "blue2" is still open
+
+
+  Scenario: A transfer token can only be used once
+    When "blue2" opens a websocket
+    And "blue2" sends:
+      """
+      {"type": "hello", "game": "9ae9f671-2714-4774-92c5-d32218c71e86", "transferToken": "{{blue.transferToken}}"}
+      """
+    And "blue2" receives:
+      """
+      {"type": "welcome", "id": "{{blue.id}}"}
+      """
+    And "blue3" opens a websocket
+    And "blue3" sends:
+      """
+      {"type": "hello", "game": "9ae9f671-2714-4774-92c5-d32218c71e86", "transferToken": "{{blue.transferToken}}"}
+      """
+    Then "blue3" receives:
+      """
+      {"type": "error", "code": "invalid-transfer-token"}
+      """
+
+
+  Scenario: A transfer token only works for the game of the peer
+    When "blue2" opens a websocket
+    And "blue2" sends:
+      """
+      {"type": "hello", "game": "3818e909-1942-42a4-8580-b7806ae7054e", "transferToken": "{{blue.transferToken}}"}
+      """
+    Then "blue2" receives:
+      """
+      {"type": "error", "code": "invalid-transfer-token"}
+      """
@@ -0,0 +1,100 @@
+Feature: Lobbies have a shared state
+
+  Background:
+    Given the "signaling" backend is running
+    And "blue" is connected to the signaling server for game "71c3c775-72f2-4357-b138-a07f0ae09442"
+    And "yellow" is connected to the signaling server for game "71c3c775-72f2-4357-b138-a07f0ae09442"
+    And "blue" sends:
+      """
+      {"type": "create", "rid": "1", "code": "stateful"}
+      """
+    And "blue" receives:
+      """
+      {"type": "joined", "rid": "1"}
+      """
+
+
+  Scenario: State keys are shared with everyone in the lobby
+    When "blue" sends:
+      """
+      {"type": "setState", "rid": "2", "key": "score", "value": {"blue": 1}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "stateUpdated", "rid": "2", "key": "score", "updatedBy": "{{blue.id}}", "value": {"blue": 1}, "owner": "{{blue.id}}", "access": "anyone", "version": 1}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "join", "rid": "3", "lobby": "stateful"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "joined", "rid": "3", "sharedState": {"score": {"value": {"blue": 1}, "version": 1}}}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "setState", "rid": "4", "key": "score", "value": {"blue": 1, "yellow": 2}}
+      """
+    Then "blue" receives:
+      """
+      {"type": "stateUpdated", "key": "score", "updatedBy": "{{yellow.id}}", "value": {"blue": 1, "yellow": 2}, "version": 2}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "getState", "rid": "5"}
+      """
+    Then "blue" receives:
+      """
+      {"type": "state", "rid": "5", "state": {"score": {"value": {"blue": 1, "yellow": 2}, "version": 2}}}
+      """
+
+
+  Scenario: Keys with owner access can only be changed by their owner
+    When "blue" sends:
+      """
+      {"type": "setState", "rid": "2", "key": "settings", "value": "hard", "access": "owner"}
+      """
+    And "blue" receives:
+      """
+      {"type": "stateUpdated", "rid": "2", "key": "settings", "access": "owner"}
+      """
+    And "yellow" sends:
+      """
+      {"type": "join", "rid": "3", "lobby": "stateful"}
+      """
+    And "yellow" receives:
+      """
+      {"type": "joined", "rid": "3"}
+      """
+
+    When "yellow" sends:
+      """
+      {"type": "setState", "rid": "4", "key": "settings", "value": "easy"}
+      """
+    Then "yellow" receives:
+      """
+      {"type": "error", "rid": "4", "code": "state-not-allowed"}
+      """
+
+
+  Scenario: A state update with an outdated version is rejected
+    When "blue" sends:
+      """
+      {"type": "setState", "rid": "2", "key": "turn", "value": 1, "expectedVersion": 0}
+      """
+    And "blue" receives:
+      """
+      {"type": "stateUpdated", "rid": "2", "key": "turn", "version": 1}
+      """
+
+    When "blue" sends:
+      """
+      {"type": "setState", "rid": "3", "key": "turn", "value": 2, "expectedVersion": 0}
+      """
+    Then "blue" receives:
+      """
+      {"type": "error", "rid": "3", "code": "version-conflict"}
+      """
@@ -1,4 +1,7 @@
 import { spawn } from 'child_process'
+import { unlinkSync, writeFileSync } from 'fs'
+import { tmpdir } from 'os'
+import { join } from 'path'
 import { After, Given } from '@cucumber/cucumber'
 import { World } from '../world'
 
@@ -14,6 +17,9 @@ Given('the {string} backend is running', async function (this: World, backend: s
     if (this.databaseURL !== undefined) {
       env.DATABASE_URL = this.databaseURL
     }
+    if (backend === 'signaling') {
+      Object.assign(env, this.backendEnv)
+    }
 
     const prc = spawn(`/tmp/netlib-cucumber-${backend}`, [], {
       windowsHide: true,
@@ -76,6 +82,24 @@ Given('the {string} backend is running', async function (this: World, backend: s
   })
 })
 
+// The steps below configure the signaling backend, they have to come before it's started.
+Given('the signaling backend uses this game config:', function (this: World, config: string) {
+  const path = join(tmpdir(), `netlib-cucumber-games-${Math.ceil(Math.random() * 1000000)}.json`)
+  writeFileSync(path, config)
+  this.backendEnv.GAME_CONFIG_FILE = path
+})
+
+Given('the signaling backend has the environment variable {string} set to {string}', function (this: World, name: string, value: string) {
+  this.backendEnv[name] = value
+})
+
+After(function (this: World) {
+  const path = this.backendEnv.GAME_CONFIG_FILE
+  if (path !== undefined) {
+    unlinkSync(path)
+  }
+})
+
 After(async function (this: World) {
   for (const [key, backend] of this.backends) {
     this.print('killing ' + key)
@@ -0,0 +1,206 @@
+import { After, Given, Then, When } from '@cucumber/cucumber'
+import { createHmac } from 'crypto'
+import WebSocket from 'ws'
+import { World } from '../world'
+import { Connection } from '../types'
+
+// These steps talk to the signaling server over a plain websocket, for
+// features of the protocol that the Network doesn't use (yet).
+
+After(async function (this: World) {
+  this.connections.forEach(c => {
+    c.ws.close()
+  })
+  this.connections.clear()
+})
+
+function getConnection (this: World, name: string): Connection {
+  const connection = this.connections.get(name)
+  if (connection === undefined) {
+    throw new Error(`no such connection ${name}`)
+  }
+  return connection
+}
+
+async function openWebsocket (this: World, name: string, params?: string, protocols?: string[], origin?: string): Promise<Connection> {
+  if (this.signalingURL === undefined) {
+    throw new Error('signaling backend not running')
+  }
+  const url = new URL(this.signalingURL)
+  if (params !== undefined) {
+    new URLSearchParams(params).forEach((value, key) => {
+      url.searchParams.set(key, value)
+    })
+  }
+  const connection = new Connection(name, new WebSocket(url.toString(), protocols, { origin }))
+  this.connections.set(name, connection)
+  await connection.open()
+  return connection
+}
+
+// fillIn replaces {{name.var}} with the vars of connection name, like {{blue.id}} or {{blue.lobby}}.
+function fillIn (this: World, text: string): string {
+  return text.replace(/\{\{(\w+)\.(\w+)\}\}/g, (_, name: string, key: string) => {
+    const value = getConnection.call(this, name).vars[key]
+    if (value === undefined) {
+      throw new Error(`${name} has not received its ${key}`)
+    }
+    return value
+  })
+}
+
+// signToken creates a HS256 signed JWT, tokens expire after an hour unless the claims have an exp.
+function signToken (claims: any, key: string): string {
+  const encode = (value: any): string => Buffer.from(JSON.stringify(value)).toString('base64url')
+  const input = `${encode({ alg: 'HS256', typ: 'JWT' })}.${encode({ exp: Math.floor(Date.now() / 1000) + 3600, ...claims })}`
+  return `${input}.${createHmac('sha256', key).update(input).digest('base64url')}`
+}
+
+When('{string} opens a websocket', async function (this: World, name: string) {
+  await openWebsocket.call(this, name)
+})
+
+When('{string} opens a websocket with the url parameters {string}', async function (this: World, name: string, params: string) {
+  await openWebsocket.call(this, name, params)
+})
+
+When('{string} opens a websocket with the subprotocols {string}', async function (this: World, name: string, protocols: string) {
+  await openWebsocket.call(this, name, undefined, protocols.split(',').map(s => s.trim()))
+})
+
+When('{string} opens a websocket from the origin {string}', async function (this: World, name: string, origin: string) {
+  await openWebsocket.call(this, name, undefined, undefined, origin)
+})
+
+Given('{string} is connected to the signaling server for game {string}', async function (this: World, name: string, gameID: string) {
+  const connection = await openWebsocket.call(this, name)
+  connection.send({ type: 'hello', game: gameID })
+  await connection.waitForPacket({ type: 'welcome' })
+})
+
+When('the admin API creates the persistent lobby {string} for game {string} with the token {string}:', async function (this: World, code: string, gameID: string, token: string, lobby: string) {
+  if (this.signalingURL === undefined) {
+    throw new Error('signaling backend not running')
+  }
+  const url = new URL(`/v0/admin/games/${gameID}/lobbies/${code}`, this.signalingURL.replace(/^ws/, 'http'))
+  const res = await fetch(url, {
+    method: 'PUT',
+    headers: { Authorization: `Bearer ${token}` },
+    body: lobby
+  })
+  if (res.status !== 200) {
+    throw new Error(`admin API returned ${res.status}: ${await res.text()}`)
+  }
+})
+
+When('{string} sends:', function (this: World, name: string, packet: string) {
+  getConnection.call(this, name).send(JSON.parse(fillIn.call(this, packet)))
+})
+
+When('{string} says hello for game {string} with a {string} signed with {string}:', function (this: World, name: string, gameID: string, field: string, key: string, claims: string) {
+  const connection = getConnection.call(this, name)
+  connection.send({ type: 'hello', game: gameID, [field]: signToken(JSON.parse(fillIn.call(this, claims)), key) })
+})
+
+Then('{string} receives:', async function (this: World, name: string, packet: string) {
+  await getConnection.call(this, name).waitForPacket(JSON.parse(fillIn.call(this, packet)))
+})
+
+Then('{string} receives the error {string}', async function (this: World, name: string, code: string) {
+  await getConnection.call(this, name).waitForPacket({ type: 'error', code })
+})
+
+Then('{string} receives a {string} packet without {string}', async function (this: World, name: string, type: string, property: string) {
+  const packet = await getConnection.call(this, name).waitForPacket({ type })
+  if (property in packet) {
+    throw new Error(`expected no ${property} in ${JSON.stringify(packet)}`)
+  }
+})
+
+Then('the lobby code of {string} matches {string}', function (this: World, name: string, pattern: string) {
+  const code = fillIn.call(this, `{{${name}.lobby}}`)
+  if (!new RegExp(`^${pattern}$`).test(code)) {
+    throw new Error(`expected lobby code ${code} to match ${pattern}`)
+  }
+})
+
+Then('{string} does not receive a {string} packet', async function (this: World, name: string, type: string) {
+  const connection = getConnection.call(this, name)
+  await new Promise(resolve => setTimeout(resolve, 1000))
+  const packet = connection.findPacket({ type })
+  if (packet !== undefined) {
+    throw new Error(`${name} received ${JSON.stringify(packet.packet)}`)
+  }
+})
+
+When('{int} seconds pass', async function (this: World, seconds: number) {
+  await new Promise(resolve => setTimeout(resolve, seconds * 1000))
+})
+
+When('the websocket of {string} is dropped', async function (this: World, name: string) {
+  const connection = getConnection.call(this, name)
+  connection.ws.terminate()
+  await connection.closed
+})
+
+Then('the websocket of {string} is closed', async function (this: World, name: string) {
+  const connection = getConnection.call(this, name)
+  let timeout: NodeJS.Timeout | undefined
+  await Promise.race([
+    connection.closed,
+    new Promise((resolve, reject) => {
+      timeout = setTimeout(() => reject(new Error(`the websocket of ${name} is still open`)), 20000)
+    })
+  ])
+  clearTimeout(timeout)
+})
+
+Then('the websocket of {string} is still open', async function (this: World, name: string) {
+  await new Promise(resolve => setTimeout(resolve, 1000))
+  if (getConnection.call(this, name).isClosed) {
+    throw new Error(`the websocket of ${name} is closed`)
+  }
+})
+
+Then('the websocket of {string} uses the subprotocol {string}', function (this: World, name: string, protocol: string) {
+  const connection = getConnection.call(this, name)
+  if (connection.ws.protocol !== protocol) {
+    throw new Error(`expected subprotocol ${protocol} but got ${connection.ws.protocol}`)
+  }
+})
+
+Then('{string} only received binary packets', function (this: World, name: string) {
+  const connection = getConnection.call(this, name)
+  if (connection.packets.length === 0) {
+    throw new Error(`${name} received no packets`)
+  }
+  const text = connection.packets.find(p => !p.binary)
+  if (text !== undefined) {
+    throw new Error(`${name} received a text packet: ${JSON.stringify(text.packet)}`)
+  }
+})
+
+Then('{string} only received text packets', function (this: World, name: string) {
+  const connection = getConnection.call(this, name)
+  if (connection.packets.length === 0) {
+    throw new Error(`${name} received no packets`)
+  }
+  const binary = connection.packets.find(p => p.binary)
+  if (binary !== undefined) {
+    throw new Error(`${name} received a binary packet: ${JSON.stringify(binary.packet)}`)
+  }
+})
+
+Then('the websocket of {string} is compressed', function (this: World, name: string) {
+  const connection = getConnection.call(this, name)
+  if (!connection.ws.extensions.includes('permessage-deflate')) {
+    throw new Error(`expected permessage-deflate but got '${connection.ws.extensions}'`)
+  }
+})
+
+Then('the websocket of {string} is not compressed', function (this: World, name: string) {
+  const connection = getConnection.call(this, name)
+  if (connection.ws.extensions.includes('permessage-deflate')) {
+    throw new Error(`expected no compression but got '${connection.ws.extensions}'`)
+  }
+})
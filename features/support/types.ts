@@ -1,3 +1,5 @@
+import WebSocket from 'ws'
+import { Packr, Unpackr } from 'msgpackr'
 import { Network } from '../../lib'
 import { LobbyListEntry } from '../../lib/types'
 
@@ -110,3 +112,127 @@ function matchEvent (e: RecordedEvent, eventName: string, matchArguments: any[]
   })
   return argumentsMatch
 }
+
+// Connections with the msgpack subprotocol use plain MessagePack maps, like the server.
+const packr = new Packr({ useRecords: false })
+const unpackr = new Unpackr({ useRecords: false, mapsAsObjects: true })
+
+interface ReceivedPacket {
+  packet: any
+  binary: boolean
+  consumed: boolean
+}
+
+// Connection is a raw websocket connection to the signaling server, it's used to test the
+// protocol directly instead of through the Network.
+export class Connection {
+  public packets: ReceivedPacket[] = []
+  public closed: Promise<void>
+  public isClosed = false
+
+  // vars are filled from the packets the connection receives, they can be used in
+  // the packets it sends as {{name.var}}.
+  public vars: Record<string, string> = {}
+
+  constructor (public name: string, public ws: WebSocket) {
+    this.closed = new Promise(resolve => {
+      ws.on('close', () => {
+        this.isClosed = true
+        resolve()
+      })
+    })
+
+    ws.on('message', (data: WebSocket.RawData, isBinary: boolean) => {
+      const packet = isBinary ? unpackr.unpack(data as Buffer) : JSON.parse(data.toString())
+      if (packet.type === 'ping') {
+        return
+      }
+      switch (packet.type) {
+        case 'welcome':
+          this.vars.id = packet.id
+          this.vars.secret = packet.secret
+          break
+        case 'joined':
+          this.vars.lobby = packet.lobbyInfo?.code ?? packet.lobby
+          break
+        case 'transferToken':
+          this.vars.transferToken = packet.token
+          break
+      }
+      this.packets.push({ packet, binary: isBinary, consumed: false })
+    })
+  }
+
+  async open (): Promise<void> {
+    if (this.ws.readyState === WebSocket.OPEN) {
+      return
+    }
+    return await new Promise((resolve, reject) => {
+      this.ws.once('open', () => resolve())
+      this.ws.once('error', reject)
+    })
+  }
+
+  send (packet: any): void {
+    if (this.ws.protocol === 'msgpack') {
+      this.ws.send(packr.pack(packet))
+    } else {
+      this.ws.send(JSON.stringify(packet))
+    }
+  }
+
+  // findPacket finds the first packet that wasn't consumed yet and matches expected.
+  // Packets published to a lobby and packets sent directly can arrive in any order,
+  // so only the matched packet is consumed instead of all packets before it.
+  findPacket (expected: any): ReceivedPacket | undefined {
+    return this.packets.find(p => !p.consumed && matchPacket(p.packet, expected))
+  }
+
+  // waitForPacket waits for a packet matching expected and consumes it.
+  async waitForPacket (expected: any): Promise<any> {
+    return await new Promise((resolve, reject) => {
+      const find = (): boolean => {
+        const found = this.findPacket(expected)
+        if (found === undefined) {
+          return false
+        }
+        found.consumed = true
+        resolve(found.packet)
+        return true
+      }
+      if (find()) {
+        return
+      }
+      const interval = setInterval(() => {
+        if (find()) {
+          clearInterval(interval)
+          clearTimeout(timeout)
+        }
+      }, 50)
+      const timeout = setTimeout(() => {
+        clearInterval(interval)
+        const others = this.packets.filter(p => !p.consumed).map(p => JSON.stringify(p.packet)).join(' + ')
+        reject(new Error(`${this.name} did not receive ${JSON.stringify(expected)}, got: ${others}`))
+      }, 20000)
+    })
+  }
+}
+
+// matchPacket checks whether actual contains everything in expected,
+// objects can have more properties but arrays must have the same length.
+// An expected null also matches a property that's missing.
+export function matchPacket (actual: any, expected: any): boolean {
+  if (expected === null) {
+    return actual === null || actual === undefined
+  }
+  if (Array.isArray(expected)) {
+    return Array.isArray(actual) && actual.length === expected.length && expected.every((e, i) => matchPacket(actual[i], e))
+  }
+  if (typeof expected === 'object') {
+    if (actual === null || typeof actual !== 'object') {
+      return false
+    }
+    return Object.keys(expected).every(key => matchPacket(actual[key], expected[key]))
+  }
+  return actual === expected
+}
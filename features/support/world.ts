@@ -6,7 +6,7 @@ import fetch from 'node-fetch'
 import ws from 'ws'
 import wrtc from '@roamhq/wrtc'
 
-import { Player } from './types'
+import { Connection, Player } from './types'
 import { PeerConfiguration } from '../../lib/types'
 
 import { Network } from '../../lib'
@@ -37,9 +37,14 @@ export class World extends CucumberWorld {
   public useTestProxy: boolean = false
   public databaseURL?: string
 
+  // backendEnv is added to the environment of the signaling backend when it's started.
+  public backendEnv: NodeJS.ProcessEnv = {}
+
   public players: Map<string, Player> = new Map<string, Player>()
   public lastError: Map<string, Error> = new Map<string, Error>()
 
+  public connections: Map<string, Connection> = new Map<string, Connection>()
+
   public print (message: string): void {
     if (this.scenarioRunning) {
       void this.attach(message)
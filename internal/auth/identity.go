@@ -0,0 +1,114 @@
+package auth
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+	"unicode/utf8"
+)
+
+const maxUserIDLength = 64
+const maxDisplayNameLength = 64
+
+// Identity is the verified identity of a logged-in player.
+type Identity struct {
+	UserID      string
+	DisplayName string
+}
+
+type identityClaims struct {
+	Subject  string          `json:"sub"`
+	Name     string          `json:"name"`
+	Nickname string          `json:"nickname"`
+	Issuer   string          `json:"iss"`
+	Audience json.RawMessage `json:"aud"`
+}
+
+// IdentityVerifier verifies identity tokens issued by the platform players log in to.
+type IdentityVerifier struct {
+	Keys     Verifier
+	Issuer   string
+	Audience string
+}
+
+// IdentityFromEnv configures identity verification from the environment:
+//
+//	IDENTITY_JWKS_FILE        JWKS file with the keys tokens can be signed with
+//	IDENTITY_PUBLIC_KEY_FILE  PEM encoded RSA or EC public key tokens are signed with
+//	IDENTITY_HMAC_KEY         HS256 key tokens are signed with
+//	IDENTITY_ISSUER           required iss claim, optional
+//	IDENTITY_AUDIENCE         required aud claim, optional
+//
+// It returns nil when no keys are configured.
+func IdentityFromEnv() (*IdentityVerifier, error) {
+	var keys Verifier
+	if path := os.Getenv("IDENTITY_JWKS_FILE"); path != "" {
+		ks, err := LoadKeySet(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = ks
+	} else if path := os.Getenv("IDENTITY_PUBLIC_KEY_FILE"); path != "" {
+		key, err := LoadPublicKey(path)
+		if err != nil {
+			return nil, err
+		}
+		keys = key
+	} else if key := os.Getenv("IDENTITY_HMAC_KEY"); key != "" {
+		keys = HMACKey(key)
+	} else {
+		return nil, nil
+	}
+
+	return &IdentityVerifier{
+		Keys:     keys,
+		Issuer:   os.Getenv("IDENTITY_ISSUER"),
+		Audience: os.Getenv("IDENTITY_AUDIENCE"),
+	}, nil
+}
+
+// Verify verifies token and returns the identity in it. The display name is
+// taken from the name claim, falling back to nickname.
+func (v *IdentityVerifier) Verify(token string, now time.Time) (Identity, error) {
+	var claims identityClaims
+	if err := Verify(token, v.Keys, now, &claims); err != nil {
+		return Identity{}, err
+	}
+	if v.Issuer != "" && claims.Issuer != v.Issuer {
+		return Identity{}, fmt.Errorf("%w: unexpected issuer", ErrInvalidToken)
+	}
+	if v.Audience != "" && !hasAudience(claims.Audience, v.Audience) {
+		return Identity{}, fmt.Errorf("%w: unexpected audience", ErrInvalidToken)
+	}
+	if claims.Subject == "" || len(claims.Subject) > maxUserIDLength {
+		return Identity{}, fmt.Errorf("%w: invalid sub claim", ErrInvalidToken)
+	}
+
+	name := claims.Name
+	if name == "" {
+		name = claims.Nickname
+	}
+	if utf8.RuneCountInString(name) > maxDisplayNameLength {
+		name = string([]rune(name)[:maxDisplayNameLength])
+	}
+
+	return Identity{
+		UserID:      claims.Subject,
+		DisplayName: name,
+	}, nil
+}
+
+// hasAudience checks the aud claim, which can be a single string or a list of strings.
+func hasAudience(raw json.RawMessage, audience string) bool {
+	var single string
+	if err := json.Unmarshal(raw, &single); err == nil {
+		return single == audience
+	}
+	var list []string
+	if err := json.Unmarshal(raw, &list); err == nil {
+		return slices.Contains(list, audience)
+	}
+	return false
+}
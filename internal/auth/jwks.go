@@ -0,0 +1,177 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+)
+
+// PublicKey verifies RS256 or ES256 signed tokens.
+type PublicKey struct {
+	Key crypto.PublicKey
+}
+
+func (k PublicKey) VerifySignature(header Header, signingInput, signature []byte) error {
+	hash := sha256.Sum256(signingInput)
+	switch key := k.Key.(type) {
+	case *rsa.PublicKey:
+		if header.Algorithm != "RS256" {
+			return fmt.Errorf("%w: unsupported algorithm %q for rsa key", ErrInvalidToken, header.Algorithm)
+		}
+		if err := rsa.VerifyPKCS1v15(key, crypto.SHA256, hash[:], signature); err != nil {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if header.Algorithm != "ES256" {
+			return fmt.Errorf("%w: unsupported algorithm %q for ec key", ErrInvalidToken, header.Algorithm)
+		}
+		if len(signature) != 64 {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+		}
+		r := new(big.Int).SetBytes(signature[:32])
+		s := new(big.Int).SetBytes(signature[32:])
+		if !ecdsa.Verify(key, hash[:], r, s) {
+			return fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported key type %T", ErrInvalidToken, k.Key)
+}
+
+// LoadPublicKey reads a PEM encoded RSA or EC public key.
+func LoadPublicKey(path string) (PublicKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("failed to read public key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return PublicKey{}, fmt.Errorf("failed to decode public key: no PEM data found")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return PublicKey{}, fmt.Errorf("failed to parse public key: %w", err)
+	}
+	return PublicKey{Key: key}, nil
+}
+
+// KeySet is a set of keys from a JWKS document, keyed by their kid.
+type KeySet map[string]Verifier
+
+func (ks KeySet) VerifySignature(header Header, signingInput, signature []byte) error {
+	if header.KeyID != "" {
+		key, found := ks[header.KeyID]
+		if !found {
+			return fmt.Errorf("%w: unknown key %q", ErrInvalidToken, header.KeyID)
+		}
+		return key.VerifySignature(header, signingInput, signature)
+	}
+
+	// Without a kid we try all keys, this is only done for sets with a single key in practice.
+	for _, key := range ks {
+		if err := key.VerifySignature(header, signingInput, signature); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: no key matches the signature", ErrInvalidToken)
+}
+
+type jwk struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+	Use     string `json:"use"`
+
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+// ParseKeySet parses a JWKS document containing RSA, P-256 EC and symmetric keys.
+func ParseKeySet(data []byte) (KeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("invalid jwks: %w", err)
+	}
+
+	ks := make(KeySet, len(doc.Keys))
+	for i, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		verifier, err := k.verifier()
+		if err != nil {
+			return nil, fmt.Errorf("invalid jwks key %d: %w", i, err)
+		}
+		ks[k.KeyID] = verifier
+	}
+	if len(ks) == 0 {
+		return nil, fmt.Errorf("invalid jwks: no signing keys")
+	}
+	return ks, nil
+}
+
+// LoadKeySet reads and parses a JWKS file.
+func LoadKeySet(path string) (KeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jwks: %w", err)
+	}
+	return ParseKeySet(data)
+}
+
+func (k jwk) verifier() (Verifier, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("invalid n: %w", err)
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("invalid e: %w", err)
+		}
+		return PublicKey{Key: &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}}, nil
+	case "EC":
+		if k.Crv != "P-256" {
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("invalid x: %w", err)
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("invalid y: %w", err)
+		}
+		return PublicKey{Key: &ecdsa.PublicKey{
+			Curve: elliptic.P256(),
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}}, nil
+	case "oct":
+		key, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, fmt.Errorf("invalid k: %w", err)
+		}
+		return HMACKey(key), nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+}
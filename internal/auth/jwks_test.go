@@ -0,0 +1,88 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+)
+
+func signWith(t *testing.T, alg, kid string, claims any, sign func(hash []byte) []byte) string {
+	t.Helper()
+	header, _ := json.Marshal(Header{Algorithm: alg, KeyID: kid})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	hash := sha256.Sum256([]byte(input))
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign(hash[:]))
+}
+
+func TestIdentityVerifierWithJWKS(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b64 := base64.RawURLEncoding.EncodeToString
+	jwks := fmt.Sprintf(`{"keys": [
+		{"kty": "RSA", "kid": "rsa", "use": "sig", "n": %q, "e": %q},
+		{"kty": "EC", "kid": "ec", "crv": "P-256", "x": %q, "y": %q}
+	]}`, b64(rsaKey.N.Bytes()), b64(exponentBytes(rsaKey.E)), b64(ecKey.X.FillBytes(make([]byte, 32))), b64(ecKey.Y.FillBytes(make([]byte, 32))))
+	keys, err := ParseKeySet([]byte(jwks))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	verifier := &IdentityVerifier{Keys: keys, Issuer: "https://id.example.com", Audience: "netlib"}
+	claims := map[string]any{"sub": "user-1", "name": "Player One", "iss": "https://id.example.com", "aud": []string{"netlib"}, "exp": now.Add(time.Hour).Unix()}
+
+	rsaToken := signWith(t, "RS256", "rsa", claims, func(hash []byte) []byte {
+		sig, err := rsa.SignPKCS1v15(rand.Reader, rsaKey, crypto.SHA256, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sig
+	})
+	identity, err := verifier.Verify(rsaToken, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.UserID != "user-1" || identity.DisplayName != "Player One" {
+		t.Errorf("unexpected identity %+v", identity)
+	}
+
+	ecToken := signWith(t, "ES256", "ec", claims, func(hash []byte) []byte {
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, hash)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	})
+	if _, err := verifier.Verify(ecToken, now); err != nil {
+		t.Fatal(err)
+	}
+
+	claims["aud"] = "other"
+	wrongAudience := signWith(t, "ES256", "ec", claims, func(hash []byte) []byte {
+		r, s, _ := ecdsa.Sign(rand.Reader, ecKey, hash)
+		return append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+	})
+	if _, err := verifier.Verify(wrongAudience, now); err == nil {
+		t.Error("expected token for another audience to be rejected")
+	}
+}
+
+func exponentBytes(e int) []byte {
+	return []byte{byte(e >> 16), byte(e >> 8), byte(e)}
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+var ErrInvalidToken = errors.New("invalid token")
+var ErrExpiredToken = errors.New("token expired")
+
+// leeway allows for small clock differences between the issuer and us.
+const leeway = 30 * time.Second
+
+// Header is the decoded header of a JWT.
+type Header struct {
+	Algorithm string `json:"alg"`
+	KeyID     string `json:"kid"`
+}
+
+// Claims are the claims of a JWT used by netlib.
+type Claims struct {
+	Subject   string `json:"sub"`
+	Game      string `json:"game"`
+	ExpiresAt int64  `json:"exp"`
+	NotBefore int64  `json:"nbf"`
+}
+
+// Verifier verifies the signature of a token.
+type Verifier interface {
+	VerifySignature(header Header, signingInput, signature []byte) error
+}
+
+// HMACKey verifies HS256 signed tokens.
+type HMACKey []byte
+
+func (k HMACKey) VerifySignature(header Header, signingInput, signature []byte) error {
+	if header.Algorithm != "HS256" {
+		return fmt.Errorf("%w: unsupported algorithm %q", ErrInvalidToken, header.Algorithm)
+	}
+	mac := hmac.New(sha256.New, k)
+	mac.Write(signingInput)
+	if !hmac.Equal(mac.Sum(nil), signature) {
+		return fmt.Errorf("%w: invalid signature", ErrInvalidToken)
+	}
+	return nil
+}
+
+// Verify checks the signature of token using verifier and decodes its claims into claims.
+// It checks the exp and nbf claims against now, tokens without exp are rejected.
+func Verify(token string, verifier Verifier, now time.Time, claims any) error {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return fmt.Errorf("%w: malformed token", ErrInvalidToken)
+	}
+
+	var header Header
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return err
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidToken)
+	}
+	if err := verifier.VerifySignature(header, []byte(parts[0]+"."+parts[1]), signature); err != nil {
+		return err
+	}
+
+	var times struct {
+		ExpiresAt *int64 `json:"exp"`
+		NotBefore int64  `json:"nbf"`
+	}
+	if err := decodeSegment(parts[1], &times); err != nil {
+		return err
+	}
+	if times.ExpiresAt == nil {
+		return fmt.Errorf("%w: missing exp claim", ErrInvalidToken)
+	}
+	if now.After(time.Unix(*times.ExpiresAt, 0).Add(leeway)) {
+		return ErrExpiredToken
+	}
+	if times.NotBefore != 0 && now.Add(leeway).Before(time.Unix(times.NotBefore, 0)) {
+		return fmt.Errorf("%w: token not valid yet", ErrInvalidToken)
+	}
+
+	return decodeSegment(parts[1], claims)
+}
+
+func decodeSegment(segment string, into any) error {
+	data, err := base64.RawURLEncoding.DecodeString(segment)
+	if err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	if err := json.Unmarshal(data, into); err != nil {
+		return fmt.Errorf("%w: malformed segment", ErrInvalidToken)
+	}
+	return nil
+}
+
+// Sign creates a HS256 signed token with the given claims. It's used by games
+// that don't have a JWT library at hand and in tests.
+func Sign(key HMACKey, claims any) (string, error) {
+	header, err := json.Marshal(Header{Algorithm: "HS256"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	signingInput := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(signingInput))
+	return signingInput + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
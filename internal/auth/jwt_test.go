@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestVerifyHMAC(t *testing.T) {
+	key := HMACKey("secret")
+	now := time.Unix(1700000000, 0)
+
+	token, err := Sign(key, Claims{Game: "game", Subject: "user", ExpiresAt: now.Add(time.Hour).Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var claims Claims
+	if err := Verify(token, key, now, &claims); err != nil {
+		t.Fatal(err)
+	}
+	if claims.Game != "game" || claims.Subject != "user" {
+		t.Errorf("unexpected claims %+v", claims)
+	}
+
+	if err := Verify(token, HMACKey("other"), now, &claims); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected invalid signature to fail, got %v", err)
+	}
+	if err := Verify(token, key, now.Add(2*time.Hour), &claims); !errors.Is(err, ErrExpiredToken) {
+		t.Errorf("expected expired token to fail, got %v", err)
+	}
+	if err := Verify(token[:len(token)-2], key, now, &claims); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected tampered token to fail, got %v", err)
+	}
+
+	noExp, _ := Sign(key, map[string]any{"game": "game"})
+	if err := Verify(noExp, key, now, &claims); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("expected token without exp to fail, got %v", err)
+	}
+}
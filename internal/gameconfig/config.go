@@ -0,0 +1,485 @@
+package gameconfig
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/poki/netlib/internal/util"
+	"github.com/xeipuuv/gojsonschema"
+)
+
+const DefaultMaxPlayers = 4
+const DefaultPeerCustomDataMaxBytes = 1024
+const DefaultSharedStateMaxKeys = 64
+const DefaultSharedStateMaxBytes = 4096
+const DefaultLobbyCleanInterval = 30 * time.Minute
+const DefaultLobbyCleanThreshold = 24 * time.Hour
+const (
+	CompressionDisabled          = "disabled"
+	CompressionContextTakeover   = "context-takeover"
+	CompressionNoContextTakeover = "no-context-takeover"
+)
+
+const DefaultPingInterval = 2 * time.Second
+const DefaultActiveUpdateInterval = 30 * time.Second
+
+// DefaultDisconnectThreshold allows a peer to miss two activity updates before it times out.
+const DefaultDisconnectThreshold = 90 * time.Second
+
+// Config holds the limits and settings for a single game.
+type Config struct {
+	// MaxLobbies is the maximum number of non-empty lobbies a game can have at once, 0 means unlimited.
+	MaxLobbies int `json:"maxLobbies"`
+
+	// MaxPeers is the maximum number of peers a game can have at once, 0 means unlimited.
+	MaxPeers int `json:"maxPeers"`
+
+	// DefaultMaxPlayers is used for lobbies that are created without maxPlayers.
+	DefaultMaxPlayers int `json:"defaultMaxPlayers"`
+
+	// MaxPlayers caps the maxPlayers setting of lobbies, 0 means there is no cap.
+	// When set, lobbies can't be unlimited (maxPlayers 0) either.
+	MaxPlayers int `json:"maxPlayers"`
+
+	// CodeFormats lists the codeFormat values lobbies can be created with, empty allows all formats.
+	CodeFormats []string `json:"codeFormats"`
+
+	// CustomDataMaxBytes is the maximum size of the json encoded customData of a lobby, 0 means unlimited.
+	CustomDataMaxBytes int `json:"customDataMaxBytes"`
+
+	// CustomDataSchema is a JSON Schema the customData of lobbies has to match.
+	CustomDataSchema json.RawMessage `json:"customDataSchema,omitempty"`
+	customDataSchema *gojsonschema.Schema
+
+	// PeerCustomDataMaxBytes is the maximum size of the json encoded customData of a peer.
+	PeerCustomDataMaxBytes int `json:"peerCustomDataMaxBytes"`
+
+	// SharedStateMaxKeys is the maximum number of shared state keys a lobby can have, 0 means unlimited.
+	SharedStateMaxKeys int `json:"sharedStateMaxKeys"`
+
+	// SharedStateMaxBytes is the maximum size of the json encoded value of a shared state key.
+	SharedStateMaxBytes int `json:"sharedStateMaxBytes"`
+
+	// PublicListing is whether lobbies can be made public and listed.
+	PublicListing bool `json:"publicListing"`
+
+	// AllowedOrigins lists the origins peers of this game can connect from, empty allows all origins.
+	// An entry like https://*.example.com allows all subdomains of example.com.
+	AllowedOrigins []string `json:"allowedOrigins"`
+
+	// LobbyCleanInterval is how often empty lobbies are cleaned up.
+	LobbyCleanInterval Duration `json:"lobbyCleanInterval"`
+
+	// LobbyCleanThreshold is how long a lobby has to be empty before it's cleaned up.
+	LobbyCleanThreshold Duration `json:"lobbyCleanThreshold"`
+
+	// PingInterval is how often peers are pinged to check if their connection is still alive.
+	PingInterval Duration `json:"pingInterval"`
+
+	// ActiveUpdateInterval is how often the last seen time of connected peers is updated.
+	ActiveUpdateInterval Duration `json:"activeUpdateInterval"`
+
+	// DisconnectThreshold is how long a peer can be gone before it times out and is removed
+	// from its lobby. Until then it can reconnect and continue where it left off.
+	DisconnectThreshold Duration `json:"disconnectThreshold"`
+
+	// Compression is the permessage-deflate mode used for websocket connections of this game,
+	// one of CompressionDisabled, CompressionContextTakeover or CompressionNoContextTakeover.
+	// It only applies to clients that put the game in the url, like ?game=<id>.
+	Compression string `json:"compression"`
+
+	// CompressionThreshold is the minimum size of a message before it's compressed, 0 uses the
+	// default of 128 bytes with context takeover and 512 bytes without.
+	CompressionThreshold int `json:"compressionThreshold"`
+
+	// CompressionMinClientVersion is the oldest client library version compression is used for,
+	// like 0.0.20. Clients pass their version in the url with ?version=, older clients and clients
+	// without a version aren't compressed.
+	CompressionMinClientVersion string `json:"compressionMinClientVersion"`
+
+	// ModerateCustomData is whether string values in the customData of lobbies and peers
+	// are checked against the moderation filter. It's off by default as customData is
+	// often not meant for people to read.
+	ModerateCustomData bool `json:"moderateCustomData"`
+
+	// CustomCodes is whether lobbies can be created with a code chosen by the client.
+	CustomCodes bool `json:"customCodes"`
+
+	// PersistentLobbies are created on startup and are never cleaned up, even when empty.
+	// They can only be configured per game.
+	PersistentLobbies []PersistentLobby `json:"persistentLobbies"`
+
+	// MinClientVersion is the oldest client library version that can connect, like 0.0.20.
+	// Older clients (and clients without a version) are rejected with upgrade-required.
+	MinClientVersion string `json:"minClientVersion"`
+
+	// SigningKey is the HS256 key used to verify the token peers present in hello.
+	// When set, peers without a valid token signed for this game are rejected.
+	SigningKey string `json:"signingKey"`
+}
+
+// Default returns the configuration used for games that aren't configured.
+func Default() Config {
+	return Config{
+		DefaultMaxPlayers:      DefaultMaxPlayers,
+		PeerCustomDataMaxBytes: DefaultPeerCustomDataMaxBytes,
+		SharedStateMaxKeys:     DefaultSharedStateMaxKeys,
+		SharedStateMaxBytes:    DefaultSharedStateMaxBytes,
+		PublicListing:          true,
+		CustomCodes:            true,
+		LobbyCleanInterval:     Duration(DefaultLobbyCleanInterval),
+		LobbyCleanThreshold:    Duration(DefaultLobbyCleanThreshold),
+		PingInterval:           Duration(DefaultPingInterval),
+		ActiveUpdateInterval:   Duration(DefaultActiveUpdateInterval),
+		DisconnectThreshold:    Duration(DefaultDisconnectThreshold),
+		Compression:            CompressionDisabled,
+	}
+}
+
+func (c Config) CheckMaxPlayers(maxPlayers int) error {
+	if maxPlayers < 0 {
+		return util.ErrorWithCode(fmt.Errorf("maxPlayers can't be negative"), "invalid-max-players")
+	}
+	if c.MaxPlayers > 0 && (maxPlayers == 0 || maxPlayers > c.MaxPlayers) {
+		return util.ErrorWithCode(fmt.Errorf("maxPlayers can't be more than %d", c.MaxPlayers), "max-players-exceeded")
+	}
+	return nil
+}
+
+func (c Config) CheckCodeFormat(format string) error {
+	if format == "" {
+		format = "default"
+	}
+	if len(c.CodeFormats) > 0 && !slices.Contains(c.CodeFormats, format) {
+		return util.ErrorWithCode(fmt.Errorf("code format %q is not allowed for this game", format), "code-format-not-allowed")
+	}
+	return nil
+}
+
+func (c Config) CheckCustomData(customData map[string]any) error {
+	return checkSize(customData, c.CustomDataMaxBytes)
+}
+
+func (c Config) CheckPeerCustomData(customData map[string]any) error {
+	return checkSize(customData, c.PeerCustomDataMaxBytes)
+}
+
+func checkSize(customData map[string]any, maxBytes int) error {
+	if maxBytes <= 0 || customData == nil {
+		return nil
+	}
+	data, err := json.Marshal(customData)
+	if err != nil {
+		return util.ErrorWithCode(fmt.Errorf("invalid customData: %w", err), "invalid-custom-data")
+	}
+	if len(data) > maxBytes {
+		return util.ErrorWithCode(fmt.Errorf("customData is %d bytes, it can't be more than %d bytes", len(data), maxBytes), "custom-data-too-large")
+	}
+	return nil
+}
+
+func (c Config) CheckPublic(public bool) error {
+	if public && !c.PublicListing {
+		return util.ErrorWithCode(fmt.Errorf("public lobbies are disabled for this game"), "public-listing-disabled")
+	}
+	return nil
+}
+
+// CheckClientVersion returns an error when clients of version can't connect.
+func (c Config) CheckClientVersion(version string) error {
+	if c.MinClientVersion == "" {
+		return nil
+	}
+	minVersion, _ := util.ParseVersion(c.MinClientVersion)
+	if v, ok := util.ParseVersion(version); !ok || v.Less(minVersion) {
+		return util.ErrorWithCode(fmt.Errorf("client version %q is too old, at least %s is required", version, minVersion), "upgrade-required")
+	}
+	return nil
+}
+
+// CompressionFor returns the compression mode for clients of version.
+func (c Config) CompressionFor(version string) string {
+	if c.CompressionMinClientVersion == "" {
+		return c.Compression
+	}
+	minVersion, _ := util.ParseVersion(c.CompressionMinClientVersion)
+	if v, ok := util.ParseVersion(version); !ok || v.Less(minVersion) {
+		return CompressionDisabled
+	}
+	return c.Compression
+}
+
+// checkLobbyCleaning returns an error when empty lobbies would be cleaned up right away.
+func (c Config) checkLobbyCleaning() error {
+	if c.LobbyCleanInterval <= 0 {
+		return fmt.Errorf("lobbyCleanInterval must be positive")
+	}
+	if c.LobbyCleanThreshold <= 0 {
+		return fmt.Errorf("lobbyCleanThreshold must be positive")
+	}
+	return nil
+}
+
+// checkTimings returns an error when peers could time out while they are still connected.
+func (c Config) checkTimings() error {
+	if c.PingInterval <= 0 {
+		return fmt.Errorf("pingInterval must be positive")
+	}
+	if c.ActiveUpdateInterval < c.PingInterval {
+		return fmt.Errorf("activeUpdateInterval can't be shorter than pingInterval")
+	}
+	if c.DisconnectThreshold <= c.ActiveUpdateInterval || time.Duration(c.DisconnectThreshold) < time.Second {
+		return fmt.Errorf("disconnectThreshold must be at least a second and longer than activeUpdateInterval")
+	}
+	return nil
+}
+
+// checkMaxPlayers returns an error when lobbies created without maxPlayers would exceed the cap.
+func (c Config) checkMaxPlayers() error {
+	if c.DefaultMaxPlayers < 0 {
+		return fmt.Errorf("defaultMaxPlayers can't be negative")
+	}
+	if c.MaxPlayers > 0 && (c.DefaultMaxPlayers == 0 || c.DefaultMaxPlayers > c.MaxPlayers) {
+		return fmt.Errorf("defaultMaxPlayers can't be more than maxPlayers")
+	}
+	return nil
+}
+
+func (c Config) checkMinClientVersion() error {
+	if _, ok := util.ParseVersion(c.MinClientVersion); c.MinClientVersion != "" && !ok {
+		return fmt.Errorf("invalid minClientVersion %q", c.MinClientVersion)
+	}
+	return nil
+}
+
+func (c Config) checkCompression() error {
+	switch c.Compression {
+	case CompressionDisabled, CompressionContextTakeover, CompressionNoContextTakeover:
+	default:
+		return fmt.Errorf("unknown compression %q", c.Compression)
+	}
+	if c.CompressionThreshold < 0 {
+		return fmt.Errorf("compressionThreshold can't be negative")
+	}
+	if _, ok := util.ParseVersion(c.CompressionMinClientVersion); c.CompressionMinClientVersion != "" && !ok {
+		return fmt.Errorf("invalid compressionMinClientVersion %q", c.CompressionMinClientVersion)
+	}
+	return nil
+}
+
+// PersistentLobby is a lobby that always exists, like a fixed named room.
+type PersistentLobby struct {
+	Code        string         `json:"code"`
+	Public      *bool          `json:"public"`
+	MaxPlayers  *int           `json:"maxPlayers"`
+	CustomData  map[string]any `json:"customData"`
+	CanUpdateBy string         `json:"canUpdateBy"`
+	Password    string         `json:"password"`
+}
+
+// CheckCustomCode returns an error when lobbies can't be created with code.
+func (c Config) CheckCustomCode(code string) error {
+	if !c.CustomCodes {
+		return util.ErrorWithCode(fmt.Errorf("custom lobby codes are disabled for this game"), "custom-codes-disabled")
+	}
+	if !util.IsValidLobbyCode(code) {
+		return util.ErrorWithCode(fmt.Errorf("invalid lobby code %q", code), "invalid-lobby-code")
+	}
+	return nil
+}
+
+// IsPersistentLobby returns whether code is the code of one of the PersistentLobbies.
+func (c Config) IsPersistentLobby(code string) bool {
+	for _, lobby := range c.PersistentLobbies {
+		if lobby.Code == code {
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsOrigin returns whether peers can connect from origin.
+func (c Config) AllowsOrigin(origin string) bool {
+	if len(c.AllowedOrigins) == 0 {
+		return true
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if strings.EqualFold(allowed, origin) {
+			return true
+		}
+		scheme, host, ok := strings.Cut(allowed, "://*.")
+		if ok {
+			prefix := strings.ToLower(scheme + "://")
+			suffix := strings.ToLower("." + host)
+			o := strings.ToLower(origin)
+			if strings.HasPrefix(o, prefix) && strings.HasSuffix(o, suffix) && len(o) > len(prefix)+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Registry holds the configuration of all games. A nil Registry returns the
+// default configuration for every game.
+type Registry struct {
+	defaults Config
+	games    map[string]Config
+}
+
+type file struct {
+	Default json.RawMessage            `json:"default"`
+	Games   map[string]json.RawMessage `json:"games"`
+}
+
+// Parse parses a configuration file in the following format, games only need
+// to specify the settings that differ from the default:
+//
+//	{
+//	  "default": {"maxPlayers": 16},
+//	  "games": {
+//	    "1a6a4c6f-...": {"maxLobbies": 100, "codeFormats": ["short"]}
+//	  }
+//	}
+func Parse(data []byte) (*Registry, error) {
+	var f file
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("invalid game config: %w", err)
+	}
+
+	r := &Registry{
+		defaults: Default(),
+		games:    make(map[string]Config, len(f.Games)),
+	}
+	var err error
+	if len(f.Default) > 0 {
+		if err := json.Unmarshal(f.Default, &r.defaults); err != nil {
+			return nil, fmt.Errorf("invalid default game config: %w", err)
+		}
+		if r.defaults.customDataSchema, err = compileSchema(r.defaults.CustomDataSchema); err != nil {
+			return nil, fmt.Errorf("invalid default game config: %w", err)
+		}
+		if err := r.defaults.checkTimings(); err != nil {
+			return nil, fmt.Errorf("invalid default game config: %w", err)
+		}
+		if err := r.defaults.checkLobbyCleaning(); err != nil {
+			return nil, fmt.Errorf("invalid default game config: %w", err)
+		}
+		if err := r.defaults.checkMaxPlayers(); err != nil {
+			return nil, fmt.Errorf("invalid default game config: %w", err)
+		}
+		if err := r.defaults.checkCompression(); err != nil {
+			return nil, fmt.Errorf("invalid default game config: %w", err)
+		}
+		if err := r.defaults.checkMinClientVersion(); err != nil {
+			return nil, fmt.Errorf("invalid default game config: %w", err)
+		}
+		if len(r.defaults.PersistentLobbies) > 0 {
+			return nil, fmt.Errorf("invalid default game config: persistentLobbies can only be set per game")
+		}
+	}
+	for game, raw := range f.Games {
+		if !util.IsUUID(game) {
+			return nil, fmt.Errorf("invalid game config: %q is not a game id", game)
+		}
+		config := r.defaults
+		if err := json.Unmarshal(raw, &config); err != nil {
+			return nil, fmt.Errorf("invalid game config for %s: %w", game, err)
+		}
+		if config.customDataSchema, err = compileSchema(config.CustomDataSchema); err != nil {
+			return nil, fmt.Errorf("invalid game config for %s: %w", game, err)
+		}
+		if err := config.checkTimings(); err != nil {
+			return nil, fmt.Errorf("invalid game config for %s: %w", game, err)
+		}
+		if err := config.checkLobbyCleaning(); err != nil {
+			return nil, fmt.Errorf("invalid game config for %s: %w", game, err)
+		}
+		if err := config.checkMaxPlayers(); err != nil {
+			return nil, fmt.Errorf("invalid game config for %s: %w", game, err)
+		}
+		if err := config.checkCompression(); err != nil {
+			return nil, fmt.Errorf("invalid game config for %s: %w", game, err)
+		}
+		if err := config.checkMinClientVersion(); err != nil {
+			return nil, fmt.Errorf("invalid game config for %s: %w", game, err)
+		}
+		for _, lobby := range config.PersistentLobbies {
+			if !util.IsValidLobbyCode(lobby.Code) {
+				return nil, fmt.Errorf("invalid game config for %s: invalid persistent lobby code %q", game, lobby.Code)
+			}
+		}
+		r.games[strings.ToLower(game)] = config
+	}
+	return r, nil
+}
+
+// Load reads and parses the configuration file at path.
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read game config: %w", err)
+	}
+	return Parse(data)
+}
+
+// FromEnv loads the configuration file set in GAME_CONFIG_FILE, it returns
+// a nil Registry when it isn't set.
+func FromEnv() (*Registry, error) {
+	path, ok := os.LookupEnv("GAME_CONFIG_FILE")
+	if !ok || path == "" {
+		return nil, nil
+	}
+	return Load(path)
+}
+
+// Defaults returns the configuration of games that aren't configured.
+func (r *Registry) Defaults() Config {
+	if r == nil {
+		return Default()
+	}
+	return r.defaults
+}
+
+// Games returns the configuration of all configured games, keyed by game ID.
+func (r *Registry) Games() map[string]Config {
+	if r == nil {
+		return nil
+	}
+	return r.games
+}
+
+// Get returns the configuration for game.
+func (r *Registry) Get(game string) Config {
+	if r == nil {
+		return Default()
+	}
+	if config, ok := r.games[strings.ToLower(game)]; ok {
+		return config
+	}
+	return r.defaults
+}
+
+// Duration is a time.Duration that is written as a string like "1h30m" in json.
+type Duration time.Duration
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("invalid duration %s, expected a string like \"30m\"", data)
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+	return nil
+}
@@ -0,0 +1,149 @@
+package gameconfig
+
+import (
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	r, err := Parse([]byte(`{
+		"default": {"maxPlayers": 16, "customDataMaxBytes": 1024},
+		"games": {
+			"9C5C9B68-1A95-11EA-BD39-9CB6D0D995F7": {"maxLobbies": 10, "codeFormats": ["short"], "publicListing": false}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	game := r.Get("9c5c9b68-1a95-11ea-bd39-9cb6d0d995f7")
+	if game.MaxLobbies != 10 || game.PublicListing {
+		t.Errorf("expected game settings to be applied, got %+v", game)
+	}
+	if game.MaxPlayers != 16 || game.CustomDataMaxBytes != 1024 || game.DefaultMaxPlayers != DefaultMaxPlayers {
+		t.Errorf("expected game to inherit the defaults, got %+v", game)
+	}
+
+	other := r.Get("0c5c9b68-1a95-11ea-bd39-9cb6d0d995f7")
+	if other.MaxLobbies != 0 || !other.PublicListing || other.MaxPlayers != 16 {
+		t.Errorf("expected unconfigured games to use the defaults, got %+v", other)
+	}
+
+	if err := game.CheckCodeFormat(""); err == nil {
+		t.Error("expected default code format to be rejected")
+	}
+	if err := game.CheckCodeFormat("short"); err != nil {
+		t.Errorf("expected short code format to be allowed: %v", err)
+	}
+	if err := game.CheckMaxPlayers(0); err == nil {
+		t.Error("expected unlimited players to be rejected when maxPlayers is capped")
+	}
+	if err := game.CheckCustomData(map[string]any{"a": string(make([]byte, 2000))}); err == nil {
+		t.Error("expected large customData to be rejected")
+	}
+
+	var nilRegistry *Registry
+	if nilRegistry.Get("anything").DefaultMaxPlayers != DefaultMaxPlayers {
+		t.Error("expected nil registry to return the default config")
+	}
+
+	if _, err := Parse([]byte(`{"games": {"not-a-uuid": {}}}`)); err == nil {
+		t.Error("expected invalid game ids to be rejected")
+	}
+	if _, err := Parse([]byte(`{"games": {"9c5c9b68-1a95-11ea-bd39-9cb6d0d995f7": {"persistentLobbies": [{"code": "EU 1"}]}}}`)); err == nil {
+		t.Error("expected invalid persistent lobby codes to be rejected")
+	}
+	if _, err := Parse([]byte(`{"default": {"persistentLobbies": [{"code": "EU-1"}]}}`)); err == nil {
+		t.Error("expected persistent lobbies in the default config to be rejected")
+	}
+	if _, err := Parse([]byte(`{"games": {"9c5c9b68-1a95-11ea-bd39-9cb6d0d995f7": {"maxPlayers": 2}}}`)); err == nil {
+		t.Error("expected a defaultMaxPlayers over maxPlayers to be rejected")
+	}
+	if _, err := Parse([]byte(`{"games": {"9c5c9b68-1a95-11ea-bd39-9cb6d0d995f7": {"disconnectThreshold": "20s"}}}`)); err == nil {
+		t.Error("expected a disconnectThreshold shorter than the activeUpdateInterval to be rejected")
+	}
+	if _, err := Parse([]byte(`{"default": {"pingInterval": "5s", "activeUpdateInterval": "5s", "disconnectThreshold": "15s"}}`)); err != nil {
+		t.Errorf("expected short timings to be allowed: %v", err)
+	}
+	if _, err := Parse([]byte(`{"games": {"9c5c9b68-1a95-11ea-bd39-9cb6d0d995f7": {"lobbyCleanThreshold": "0s"}}}`)); err == nil {
+		t.Error("expected a lobbyCleanThreshold of 0 to be rejected")
+	}
+	if _, err := Parse([]byte(`{"default": {"lobbyCleanInterval": "-1m"}}`)); err == nil {
+		t.Error("expected a negative lobbyCleanInterval to be rejected")
+	}
+	if _, err := Parse([]byte(`{"default": {"compression": "gzip"}}`)); err == nil {
+		t.Error("expected unknown compression modes to be rejected")
+	}
+	if _, err := Parse([]byte(`{"default": {"compressionMinClientVersion": "latest"}}`)); err == nil {
+		t.Error("expected invalid compressionMinClientVersion to be rejected")
+	}
+	if _, err := Parse([]byte(`{"default": {"minClientVersion": "latest"}}`)); err == nil {
+		t.Error("expected invalid minClientVersion to be rejected")
+	}
+
+	versioned := Config{MinClientVersion: "0.1.0"}
+	if err := versioned.CheckClientVersion("0.0.20"); err == nil {
+		t.Error("expected older clients to be rejected")
+	}
+	if err := versioned.CheckClientVersion(""); err == nil {
+		t.Error("expected clients without a version to be rejected")
+	}
+	if err := versioned.CheckClientVersion("0.1.0"); err != nil {
+		t.Errorf("expected the minimum version to be allowed: %v", err)
+	}
+
+	compressed := Config{Compression: CompressionContextTakeover, CompressionMinClientVersion: "0.1.0"}
+	if mode := compressed.CompressionFor("0.0.20"); mode != CompressionDisabled {
+		t.Errorf("expected older clients not to be compressed, got %s", mode)
+	}
+	if mode := compressed.CompressionFor(""); mode != CompressionDisabled {
+		t.Errorf("expected clients without a version not to be compressed, got %s", mode)
+	}
+	if mode := compressed.CompressionFor("0.1.0"); mode != CompressionContextTakeover {
+		t.Errorf("expected the minimum version to be compressed, got %s", mode)
+	}
+}
+
+func TestValidateCustomData(t *testing.T) {
+	r, err := Parse([]byte(`{
+		"games": {
+			"9c5c9b68-1a95-11ea-bd39-9cb6d0d995f7": {
+				"customDataSchema": {
+					"type": "object",
+					"properties": {
+						"map": {"type": "string", "maxLength": 20},
+						"players": {"type": "array", "items": {"type": "object", "properties": {"name": {"type": "string"}}}}
+					},
+					"additionalProperties": false
+				}
+			}
+		}
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	game := r.Get("9c5c9b68-1a95-11ea-bd39-9cb6d0d995f7")
+
+	if err := game.ValidateCustomData(map[string]any{"map": "forest"}); err != nil {
+		t.Errorf("expected valid customData to pass: %v", err)
+	}
+	if err := game.ValidateCustomData(nil); err != nil {
+		t.Errorf("expected no customData to pass: %v", err)
+	}
+
+	err = game.ValidateCustomData(map[string]any{"players": []any{map[string]any{"name": 12}}})
+	cerr, ok := err.(*CustomDataError)
+	if !ok {
+		t.Fatalf("expected a CustomDataError, got %v", err)
+	}
+	if cerr.Path != "customData.players.0.name" || cerr.ErrorCode() != "invalid-custom-data" {
+		t.Errorf("unexpected error %+v", cerr)
+	}
+
+	if err := r.Get("0c5c9b68-1a95-11ea-bd39-9cb6d0d995f7").ValidateCustomData(map[string]any{"anything": true}); err != nil {
+		t.Errorf("expected games without a schema to accept any customData: %v", err)
+	}
+
+	if _, err := Parse([]byte(`{"default": {"customDataSchema": {"type": 12}}}`)); err == nil {
+		t.Error("expected invalid schemas to be rejected")
+	}
+}
@@ -0,0 +1,64 @@
+package gameconfig
+
+import (
+	"fmt"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+// CustomDataError is returned when customData doesn't match the customDataSchema of a game.
+type CustomDataError struct {
+	// Path is the path of the failing value, like customData.players.1.name.
+	Path   string `json:"path"`
+	Reason string `json:"reason"`
+}
+
+func (e *CustomDataError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Path, e.Reason)
+}
+
+func (e *CustomDataError) ErrorCode() string {
+	return "invalid-custom-data"
+}
+
+func compileSchema(raw []byte) (*gojsonschema.Schema, error) {
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	schema, err := gojsonschema.NewSchema(gojsonschema.NewBytesLoader(raw))
+	if err != nil {
+		return nil, fmt.Errorf("invalid customDataSchema: %w", err)
+	}
+	return schema, nil
+}
+
+// ValidateCustomData checks the size of the customData of a lobby and validates it against
+// the customDataSchema of the game.
+func (c Config) ValidateCustomData(customData map[string]any) error {
+	if err := c.CheckCustomData(customData); err != nil {
+		return err
+	}
+	if c.customDataSchema == nil {
+		return nil
+	}
+
+	var document any = customData
+	if customData == nil {
+		// A lobby without customData is validated as an empty object.
+		document = map[string]any{}
+	}
+	result, err := c.customDataSchema.Validate(gojsonschema.NewGoLoader(document))
+	if err != nil {
+		return &CustomDataError{Path: "customData", Reason: err.Error()}
+	}
+	if result.Valid() {
+		return nil
+	}
+
+	first := result.Errors()[0]
+	path := "customData"
+	if field := first.Field(); field != "" && field != gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+		path += "." + field
+	}
+	return &CustomDataError{Path: path, Reason: first.Description()}
+}
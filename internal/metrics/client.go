@@ -1,28 +1,16 @@
 package metrics
 
 import (
-	"bytes"
 	"context"
-	"encoding/json"
-	"io"
-	"math/rand"
-	"net"
-	"net/http"
+	"errors"
 	"os"
-	"time"
+	"sync"
 
 	"github.com/koenbollen/logging"
 	"github.com/poki/netlib/internal/util"
-	"github.com/rs/xid"
 	"go.uber.org/zap"
 )
 
-const timeout = 10 * time.Second
-const maxIdleConnsPerHost = 32
-const maxConnsPerHost = 32
-const maxRetries = 5
-const backoffRange = 1000 // milliseconds, picked randomly from a range times the number of retries
-
 type EventParams struct {
 	Game     string `json:"game"`
 	Category string `json:"category"`
@@ -33,27 +21,15 @@ type EventParams struct {
 	Data map[string]string `json:"data,omitempty"`
 }
 
+// Client records events and hands them to every configured Sink.
 type Client struct {
-	url    string
-	client http.Client
+	sinks []Sink
 }
 
-func NewClient(url string) *Client {
-	c := &Client{
-		url: url,
-		client: http.Client{
-			Timeout: timeout,
-			Transport: &http.Transport{
-				MaxConnsPerHost:     maxConnsPerHost,
-				MaxIdleConnsPerHost: maxIdleConnsPerHost,
-				Dial: (&net.Dialer{
-					Timeout: timeout,
-				}).Dial,
-				TLSHandshakeTimeout: timeout,
-			},
-		},
+func NewClient(sinks ...Sink) *Client {
+	return &Client{
+		sinks: sinks,
 	}
-	return c
 }
 
 func (c *Client) Record(ctx context.Context, category, action, game, peerID, lobbyID string, data ...string) {
@@ -78,7 +54,6 @@ func (c *Client) RecordEvent(ctx context.Context, params EventParams) {
 	logger := logging.GetLogger(ctx)
 	now := util.NowUTC(ctx)
 	remoteAddr, _ := ctx.Value(remoteAddrKey).(string)
-	userAgent, _ := ctx.Value(userAgentKey).(string)
 
 	event := &Event{
 		Time:    now.UnixMilli(),
@@ -94,58 +69,28 @@ func (c *Client) RecordEvent(ctx context.Context, params EventParams) {
 		Data: params.Data,
 	}
 
-	payload, err := json.Marshal(event)
-	if err != nil {
-		logger.Error("failed to marshal event", zap.Error(err))
-		return
-	}
-
-	idempotency := xid.New().String()
-	logger = logger.With(zap.String("idempotency", idempotency))
-
-	for i := range maxRetries {
-		lastAttempt := i == maxRetries-1
-
-		if i > 0 {
-			time.Sleep(time.Duration(rand.Int63n(backoffRange)*int64(i)) * time.Millisecond)
-		}
-
-		// Use a new context, we want to record events of users that are already disconnected.
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
-		defer cancel()
-
-		req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(payload))
-		if err != nil {
-			logger.Error("failed to create metrics request", zap.Error(err))
-			return
-		}
-		req.Header.Set("X-Idempotency-ID", idempotency)
-		if userAgent != "" {
-			req.Header.Set("User-Agent", userAgent)
-		}
-
-		resp, err := c.client.Do(req)
-		if err != nil {
-			if !lastAttempt {
-				logger.Warn("failed execute metrics request, retrying", zap.Int("attempt", i), zap.Error(err))
-			} else {
-				logger.Error("failed execute metrics request", zap.Error(err))
+	// Every sink is written to in its own goroutine, so the retries of a slow
+	// sink don't delay the event for the other sinks.
+	var wg sync.WaitGroup
+	for _, sink := range c.sinks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := sink.Write(ctx, event); err != nil {
+				logger.Error("failed to write metrics event", zap.String("sink", sink.Name()), zap.Error(err))
 			}
-			continue
-		}
-		io.Copy(io.Discard, resp.Body) //nolint:errcheck
-		resp.Body.Close()              //nolint:errcheck
+		}()
+	}
+	wg.Wait()
+}
 
-		if resp.StatusCode != http.StatusNoContent {
-			if lastAttempt {
-				logger.Error("unexpected status code from metrics endpoint", zap.Int("status", resp.StatusCode))
-			}
-			if resp.StatusCode/100 == 5 {
-				continue
-			}
-			return
+// Close closes all sinks, flushing any buffered events.
+func (c *Client) Close() error {
+	var errs []error
+	for _, sink := range c.sinks {
+		if err := sink.Close(); err != nil {
+			errs = append(errs, err)
 		}
-
-		return
 	}
+	return errors.Join(errs...)
 }
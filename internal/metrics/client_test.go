@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// channelSink sends every event to a channel, it blocks until the event is received.
+type channelSink struct {
+	name   string
+	events chan *Event
+}
+
+func (s *channelSink) Name() string {
+	return s.name
+}
+
+func (s *channelSink) Write(ctx context.Context, event *Event) error {
+	s.events <- event
+	return nil
+}
+
+func (s *channelSink) Close() error {
+	return nil
+}
+
+func TestClientSlowSinkDoesNotBlockOthers(t *testing.T) {
+	slow := &channelSink{name: "slow", events: make(chan *Event)}
+	fast := &channelSink{name: "fast", events: make(chan *Event, 1)}
+	client := NewClient(slow, fast)
+
+	done := make(chan struct{})
+	go func() {
+		client.Record(context.Background(), "test", "record", "game", "peer", "")
+		close(done)
+	}()
+
+	select {
+	case event := <-fast.events:
+		if event.Action != "record" {
+			t.Errorf("expected the record event, got %q", event.Action)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("fast sink was blocked by the slow sink")
+	}
+
+	<-slow.events
+	<-done
+}
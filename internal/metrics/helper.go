@@ -3,7 +3,8 @@ package metrics
 import (
 	"context"
 	"net/http"
-	"strings"
+
+	"github.com/poki/netlib/internal/util"
 )
 
 type metricsContextKey int
@@ -17,11 +18,7 @@ func Middleware(next http.Handler, client *Client) http.Handler {
 		ctx := r.Context()
 		ctx = context.WithValue(ctx, clientKey, client)
 
-		remoteAddr := r.RemoteAddr
-		if r.Header.Get("X-Forwarded-For") != "" {
-			remoteAddr = strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-For"), ",")[0])
-		}
-		ctx = context.WithValue(ctx, remoteAddrKey, remoteAddr)
+		ctx = context.WithValue(ctx, remoteAddrKey, util.RemoteAddr(r))
 		ctx = context.WithValue(ctx, userAgentKey, r.UserAgent())
 
 		next.ServeHTTP(w, r.WithContext(ctx))
@@ -0,0 +1,122 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+)
+
+// Sink is a destination for recorded events. Write is called from a goroutine
+// per sink and may block, implementations must be safe for concurrent use.
+type Sink interface {
+	Name() string
+	Write(ctx context.Context, event *Event) error
+	Close() error
+}
+
+// WriterSink writes every event as a single JSON line to an io.Writer.
+type WriterSink struct {
+	mutex   sync.Mutex
+	name    string
+	encoder *json.Encoder
+}
+
+func NewWriterSink(name string, w io.Writer) *WriterSink {
+	return &WriterSink{
+		name:    name,
+		encoder: json.NewEncoder(w),
+	}
+}
+
+// NewStdoutSink returns a sink writing JSON lines to stdout.
+func NewStdoutSink() *WriterSink {
+	return NewWriterSink("stdout", os.Stdout)
+}
+
+func (s *WriterSink) Name() string {
+	return s.name
+}
+
+func (s *WriterSink) Write(ctx context.Context, event *Event) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.encoder.Encode(event)
+}
+
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+// FromEnv creates a Client with a sink for each configured environment variable:
+//
+//	METRICS_URL               post events to this HTTP endpoint
+//	METRICS_STDOUT            write events as JSON lines to stdout when set to true
+//	METRICS_FILE              write events as JSON lines to this file, rotating it when it grows too big
+//	METRICS_FILE_MAX_SIZE     maximum size in bytes before the file is rotated (default 100MB)
+//	METRICS_FILE_MAX_BACKUPS  number of rotated files to keep (default 5)
+//	METRICS_KAFKA_REST_URL    produce events through a Kafka REST proxy at this URL
+//	METRICS_KAFKA_TOPIC       topic to produce to (default netlib-events)
+//
+// It returns a nil Client when no sink is configured.
+func FromEnv(ctx context.Context) (*Client, error) {
+	var sinks []Sink
+
+	if url, ok := os.LookupEnv("METRICS_URL"); ok {
+		sinks = append(sinks, NewHTTPSink(url))
+	}
+
+	if v, ok := os.LookupEnv("METRICS_STDOUT"); ok {
+		enabled, err := strconv.ParseBool(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid METRICS_STDOUT: %w", err)
+		}
+		if enabled {
+			sinks = append(sinks, NewStdoutSink())
+		}
+	}
+
+	if path, ok := os.LookupEnv("METRICS_FILE"); ok {
+		maxSize, err := int64FromEnv("METRICS_FILE_MAX_SIZE", defaultFileMaxSize)
+		if err != nil {
+			return nil, err
+		}
+		maxBackups, err := int64FromEnv("METRICS_FILE_MAX_BACKUPS", defaultFileMaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		sink, err := NewFileSink(path, maxSize, int(maxBackups))
+		if err != nil {
+			return nil, err
+		}
+		sinks = append(sinks, sink)
+	}
+
+	if url, ok := os.LookupEnv("METRICS_KAFKA_REST_URL"); ok {
+		topic := os.Getenv("METRICS_KAFKA_TOPIC")
+		if topic == "" {
+			topic = defaultKafkaTopic
+		}
+		sinks = append(sinks, NewKafkaRESTSink(url, topic))
+	}
+
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return NewClient(sinks...), nil
+}
+
+func int64FromEnv(key string, def int64) (int64, error) {
+	v, ok := os.LookupEnv(key)
+	if !ok {
+		return def, nil
+	}
+	i, err := strconv.ParseInt(v, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %w", key, err)
+	}
+	return i, nil
+}
@@ -0,0 +1,119 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sync"
+)
+
+const defaultFileMaxSize = 100 * 1024 * 1024
+const defaultFileMaxBackups = 5
+
+// FileSink appends events as JSON lines to a local file. When the file grows
+// beyond maxSize it is rotated to path.1, path.1 to path.2 and so on, keeping
+// at most maxBackups old files.
+type FileSink struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+
+	mutex sync.Mutex
+	file  *os.File
+	size  int64
+}
+
+func NewFileSink(path string, maxSize int64, maxBackups int) (*FileSink, error) {
+	s := &FileSink{
+		path:       path,
+		maxSize:    maxSize,
+		maxBackups: maxBackups,
+	}
+	if err := s.open(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *FileSink) open() error {
+	file, err := os.OpenFile(s.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open metrics file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close() //nolint:errcheck
+		return fmt.Errorf("failed to stat metrics file: %w", err)
+	}
+	s.file = file
+	s.size = info.Size()
+	return nil
+}
+
+func (s *FileSink) Name() string {
+	return "file"
+}
+
+func (s *FileSink) Write(ctx context.Context, event *Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	line = append(line, '\n')
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return fmt.Errorf("metrics file is closed")
+	}
+
+	if s.maxSize > 0 && s.size > 0 && s.size+int64(len(line)) > s.maxSize {
+		if err := s.rotate(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(line)
+	s.size += int64(n)
+	return err
+}
+
+func (s *FileSink) rotate() error {
+	if err := s.file.Close(); err != nil {
+		return fmt.Errorf("failed to close metrics file: %w", err)
+	}
+	s.file = nil
+
+	if s.maxBackups <= 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove metrics file: %w", err)
+		}
+	} else {
+		for i := s.maxBackups - 1; i > 0; i-- {
+			from := fmt.Sprintf("%s.%d", s.path, i)
+			to := fmt.Sprintf("%s.%d", s.path, i+1)
+			if err := os.Rename(from, to); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to rotate metrics file: %w", err)
+			}
+		}
+		if err := os.Rename(s.path, s.path+".1"); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to rotate metrics file: %w", err)
+		}
+	}
+
+	return s.open()
+}
+
+func (s *FileSink) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+	err := s.file.Close()
+	s.file = nil
+	return err
+}
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestFileSinkRotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+
+	sink, err := NewFileSink(path, 200, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sink.Close() //nolint:errcheck
+
+	for i := 0; i < 10; i++ {
+		if err := sink.Write(context.Background(), &Event{Game: "game", Category: "test", Action: "write"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			t.Fatalf("expected %s to exist: %v", name, err)
+		}
+		if len(data) > 200 {
+			t.Errorf("expected %s to be at most 200 bytes, got %d", name, len(data))
+		}
+		if !strings.HasSuffix(string(data), "}\n") {
+			t.Errorf("expected %s to contain complete json lines", name)
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Errorf("expected only 2 backups to be kept")
+	}
+}
@@ -0,0 +1,121 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/koenbollen/logging"
+	"github.com/rs/xid"
+	"go.uber.org/zap"
+)
+
+const timeout = 10 * time.Second
+const maxIdleConnsPerHost = 32
+const maxConnsPerHost = 32
+const maxRetries = 5
+const backoffRange = 1000 // milliseconds, picked randomly from a range times the number of retries
+
+// HTTPSink posts every event as a JSON document to an HTTP endpoint,
+// retrying on network and 5xx errors.
+type HTTPSink struct {
+	url    string
+	client http.Client
+}
+
+func NewHTTPSink(url string) *HTTPSink {
+	return &HTTPSink{
+		url:    url,
+		client: newHTTPClient(),
+	}
+}
+
+func newHTTPClient() http.Client {
+	return http.Client{
+		Timeout: timeout,
+		Transport: &http.Transport{
+			MaxConnsPerHost:     maxConnsPerHost,
+			MaxIdleConnsPerHost: maxIdleConnsPerHost,
+			Dial: (&net.Dialer{
+				Timeout: timeout,
+			}).Dial,
+			TLSHandshakeTimeout: timeout,
+		},
+	}
+}
+
+func (s *HTTPSink) Name() string {
+	return "http"
+}
+
+func (s *HTTPSink) Write(ctx context.Context, event *Event) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return postWithRetries(ctx, &s.client, s.url, "application/json", payload)
+}
+
+func (s *HTTPSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
+
+// postWithRetries posts the payload to url, retrying with a randomized backoff.
+// It expects a 2xx response, on 5xx responses and network errors it will retry.
+func postWithRetries(ctx context.Context, client *http.Client, url, contentType string, payload []byte) error {
+	logger := logging.GetLogger(ctx)
+	userAgent, _ := ctx.Value(userAgentKey).(string)
+
+	idempotency := xid.New().String()
+	logger = logger.With(zap.String("idempotency", idempotency))
+
+	var lastErr error
+	for i := range maxRetries {
+		if i > 0 {
+			time.Sleep(time.Duration(rand.Int63n(backoffRange)*int64(i)) * time.Millisecond)
+		}
+
+		// Use a new context, we want to record events of users that are already disconnected.
+		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		defer cancel()
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+		if err != nil {
+			return fmt.Errorf("failed to create metrics request: %w", err)
+		}
+		req.Header.Set("Content-Type", contentType)
+		req.Header.Set("X-Idempotency-ID", idempotency)
+		if userAgent != "" {
+			req.Header.Set("User-Agent", userAgent)
+		}
+
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = fmt.Errorf("failed execute metrics request: %w", err)
+			if i < maxRetries-1 {
+				logger.Warn("failed execute metrics request, retrying", zap.Int("attempt", i), zap.Error(err))
+			}
+			continue
+		}
+		io.Copy(io.Discard, resp.Body) //nolint:errcheck
+		resp.Body.Close()              //nolint:errcheck
+
+		if resp.StatusCode/100 != 2 {
+			lastErr = fmt.Errorf("unexpected status code from metrics endpoint: %d", resp.StatusCode)
+			if resp.StatusCode/100 == 5 {
+				continue
+			}
+			return lastErr
+		}
+
+		return nil
+	}
+	return lastErr
+}
@@ -0,0 +1,52 @@
+package metrics
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const defaultKafkaTopic = "netlib-events"
+
+// KafkaRESTSink produces events to a Kafka topic through a Kafka REST proxy
+// (the Confluent REST Proxy v2 API, also implemented by Redpanda and others).
+// Events are keyed by game so all events of a game end up in the same partition.
+type KafkaRESTSink struct {
+	url    string
+	client http.Client
+}
+
+func NewKafkaRESTSink(baseURL, topic string) *KafkaRESTSink {
+	return &KafkaRESTSink{
+		url:    strings.TrimSuffix(baseURL, "/") + "/topics/" + url.PathEscape(topic),
+		client: newHTTPClient(),
+	}
+}
+
+func (s *KafkaRESTSink) Name() string {
+	return "kafka"
+}
+
+func (s *KafkaRESTSink) Write(ctx context.Context, event *Event) error {
+	type record struct {
+		Key   string `json:"key,omitempty"`
+		Value *Event `json:"value"`
+	}
+	payload, err := json.Marshal(struct {
+		Records []record `json:"records"`
+	}{
+		Records: []record{{Key: event.Game, Value: event}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to marshal event: %w", err)
+	}
+	return postWithRetries(ctx, &s.client, s.url, "application/vnd.kafka.json.v2+json", payload)
+}
+
+func (s *KafkaRESTSink) Close() error {
+	s.client.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,215 @@
+package moderation
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// defaultWords are blocked when no other words are configured. Words are
+// matched as whole words after normalizing, see words.
+var defaultWords = []string{
+	"asshole", "bitch", "boob", "bullshit", "cock", "cunt", "dick", "dickhead", "dildo", "fag",
+	"faggot", "fuck", "fucker", "jizz", "kkk", "motherfucker", "nazi", "nigga", "nigger", "penis",
+	"piss", "porn", "pussy", "shit", "slut", "twat", "vagina", "wank", "whore",
+}
+
+// suffixes are the endings a blocked word can have and still be blocked, like "shits" or "fucking".
+var suffixes = []string{"", "s", "es", "ed", "er", "ers", "ing"}
+
+// Filter checks text against a list of blocked words and regular expressions.
+// A nil Filter blocks nothing.
+type Filter struct {
+	words    []string
+	patterns []*regexp.Regexp
+}
+
+// NewFilter returns a Filter that blocks text containing any of words or matching any of patterns.
+func NewFilter(words []string, patterns []string) (*Filter, error) {
+	f := &Filter{}
+	for _, word := range words {
+		if w := normalize(word); w != "" {
+			f.words = append(f.words, w)
+		}
+	}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern %q: %w", pattern, err)
+		}
+		f.patterns = append(f.patterns, re)
+	}
+	return f, nil
+}
+
+// Default returns a Filter with a built-in list of profanity.
+func Default() *Filter {
+	f, _ := NewFilter(defaultWords, nil)
+	return f
+}
+
+// FromEnv returns the Filter configured with the following environment variables:
+//   - MODERATION_WORDS_FILE: file with a blocked word per line, replaces the built-in list.
+//   - MODERATION_PATTERNS_FILE: file with a regular expression per line, for example (?i)\bbuy\s+gold\b.
+//   - MODERATION_DISABLED: set to true to disable moderation, FromEnv returns a nil Filter.
+//
+// Empty lines and lines starting with # are ignored in both files.
+func FromEnv() (*Filter, error) {
+	if os.Getenv("MODERATION_DISABLED") == "true" {
+		return nil, nil
+	}
+
+	words := defaultWords
+	if path := os.Getenv("MODERATION_WORDS_FILE"); path != "" {
+		var err error
+		if words, err = readLines(path); err != nil {
+			return nil, err
+		}
+	}
+	var patterns []string
+	if path := os.Getenv("MODERATION_PATTERNS_FILE"); path != "" {
+		var err error
+		if patterns, err = readLines(path); err != nil {
+			return nil, err
+		}
+	}
+	return NewFilter(words, patterns)
+}
+
+func readLines(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read moderation list: %w", err)
+	}
+	defer file.Close() //nolint:errcheck
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line != "" && !strings.HasPrefix(line, "#") {
+			lines = append(lines, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read moderation list: %w", err)
+	}
+	return lines, nil
+}
+
+// Blocked returns whether text contains a blocked word or matches a blocked pattern.
+// Words only match whole words of the text, so "Scunthorpe" and "peacock" aren't blocked
+// but "F-U-C-K" and "5h1t" are, see words.
+func (f *Filter) Blocked(text string) bool {
+	if f == nil {
+		return false
+	}
+	for _, word := range words(text) {
+		for _, blocked := range f.words {
+			if rest, ok := strings.CutPrefix(word, blocked); ok && slices.Contains(suffixes, rest) {
+				return true
+			}
+		}
+	}
+	for _, re := range f.patterns {
+		if re.MatchString(text) {
+			return true
+		}
+	}
+	return false
+}
+
+// BlockedPath walks the decoded json value data and returns the path of the first
+// string that is blocked, like "name" or "players[2].nickname". Only values are checked, not keys.
+func (f *Filter) BlockedPath(data any) (string, bool) {
+	if f == nil {
+		return "", false
+	}
+	return f.blockedPath(data, "")
+}
+
+func (f *Filter) blockedPath(data any, path string) (string, bool) {
+	switch v := data.(type) {
+	case string:
+		return path, f.Blocked(v)
+	case map[string]any:
+		// Sort the keys so the same path is returned every time.
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			child := key
+			if path != "" {
+				child = path + "." + key
+			}
+			if p, blocked := f.blockedPath(v[key], child); blocked {
+				return p, true
+			}
+		}
+	case []any:
+		for i, item := range v {
+			if p, blocked := f.blockedPath(item, path+"["+strconv.Itoa(i)+"]"); blocked {
+				return p, true
+			}
+		}
+	}
+	return "", false
+}
+
+var leetReplacer = strings.NewReplacer(
+	"0", "o",
+	"1", "i",
+	"3", "e",
+	"4", "a",
+	"5", "s",
+	"7", "t",
+	"8", "b",
+	"@", "a",
+	"$", "s",
+	"!", "i",
+)
+
+// words splits text into normalized words. Runs of single letters are joined into one word,
+// so spelled out words like f-u-c-k are found too.
+func words(text string) []string {
+	text = leetReplacer.Replace(strings.ToLower(text))
+	fields := strings.FieldsFunc(text, func(r rune) bool {
+		return r < 'a' || r > 'z'
+	})
+	var words []string
+	letters := ""
+	for _, field := range fields {
+		if len(field) == 1 {
+			letters += field
+			continue
+		}
+		if letters != "" {
+			words = append(words, letters)
+			letters = ""
+		}
+		words = append(words, field)
+	}
+	if letters != "" {
+		words = append(words, letters)
+	}
+	return words
+}
+
+// normalize lowercases text, replaces common character substitutions and
+// removes everything that isn't a letter.
+func normalize(text string) string {
+	text = leetReplacer.Replace(strings.ToLower(text))
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' {
+			return r
+		}
+		return -1
+	}, text)
+}
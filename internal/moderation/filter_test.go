@@ -0,0 +1,62 @@
+package moderation
+
+import "testing"
+
+func TestFilter(t *testing.T) {
+	f := Default()
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"brave-otter-42", false},
+		{"Friday Night", false},
+		{"SHIT", true},
+		{"5h1t", true},
+		{"f-u-c-k", true},
+		{"shits", true},
+		{"what the fucking", true},
+		{"Scunthorpe", false},
+		{"Charles Dickens", false},
+		{"Hancock", false},
+		{"peacock", false},
+		{"c2hpdCBmdWNr", false},
+		{"deadbeef0123cafe", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := f.Blocked(tt.in); got != tt.want {
+			t.Errorf("Blocked(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+
+	var nilFilter *Filter
+	if nilFilter.Blocked("shit") {
+		t.Error("expected a nil filter to block nothing")
+	}
+}
+
+func TestFilterPatterns(t *testing.T) {
+	f, err := NewFilter([]string{"badword"}, []string{`(?i)\bbuy\s+gold\b`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !f.Blocked("BUY   gold here") {
+		t.Error("expected pattern to block text")
+	}
+	if f.Blocked("shit") {
+		t.Error("expected configured words to replace the defaults")
+	}
+
+	path, blocked := f.BlockedPath(map[string]any{
+		"title":   "fine",
+		"players": []any{"alice", map[string]any{"name": "b-a-d-w-o-r-d"}},
+		"score":   12.0,
+	})
+	if !blocked || path != "players[1].name" {
+		t.Errorf("BlockedPath() = %q, %v, want players[1].name, true", path, blocked)
+	}
+
+	if _, err := NewFilter(nil, []string{"("}); err == nil {
+		t.Error("expected invalid patterns to be rejected")
+	}
+}
@@ -11,15 +11,18 @@ import (
 	"github.com/poki/netlib/internal/util"
 )
 
-func Signaling(ctx context.Context, store stores.Store, credentialsClient *cloudflare.CredentialsClient) (http.Handler, func()) {
+func Signaling(ctx context.Context, store stores.Store, credentialsClient *cloudflare.CredentialsClient, options signaling.HandlerOptions) (http.Handler, func()) {
 	mux := http.NewServeMux()
 
-	openConnections, signaling := signaling.Handler(ctx, store, credentialsClient)
+	openConnections, handler := signaling.Handler(ctx, store, credentialsClient, options)
 
 	cleanup := func() {
 		openConnections.Wait()
 	}
-	mux.Handle("/v0/signaling", signaling)
+	mux.Handle("/v0/signaling", handler)
+	if options.AdminToken != "" {
+		mux.Handle("/v0/admin/", signaling.AdminHandler(store, options.AdminToken))
+	}
 
 	hasCredentials := uint32(0)
 	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
@@ -0,0 +1,119 @@
+package signaling
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/koenbollen/logging"
+	"github.com/poki/netlib/internal/gameconfig"
+	"github.com/poki/netlib/internal/signaling/stores"
+	"github.com/poki/netlib/internal/util"
+	"go.uber.org/zap"
+)
+
+// AdminHandler serves the admin API used to manage persistent lobbies:
+//
+//	PUT    /v0/admin/games/{game}/lobbies/{code}  create or update a persistent lobby
+//	DELETE /v0/admin/games/{game}/lobbies/{code}  make a lobby non-persistent again
+//
+// All requests must have an Authorization: Bearer <token> header.
+func AdminHandler(store stores.Store, token string) http.Handler {
+	mux := http.NewServeMux()
+
+	mux.HandleFunc("PUT /v0/admin/games/{game}/lobbies/{code}", func(w http.ResponseWriter, r *http.Request) {
+		game, code := r.PathValue("game"), r.PathValue("code")
+		if !util.IsUUID(game) {
+			util.ErrorAndAbort(w, r, http.StatusBadRequest, "invalid-game")
+		}
+		if !util.IsValidLobbyCode(code) {
+			util.ErrorAndAbort(w, r, http.StatusBadRequest, "invalid-lobby-code")
+		}
+
+		var lobby gameconfig.PersistentLobby
+		if err := json.NewDecoder(r.Body).Decode(&lobby); err != nil {
+			util.ErrorAndAbort(w, r, http.StatusBadRequest, "invalid-body", err)
+		}
+		lobby.Code = code
+		if !isValidCanUpdateBy(lobby.CanUpdateBy) {
+			util.ErrorAndAbort(w, r, http.StatusBadRequest, "invalid-can-update-by")
+		}
+
+		if err := upsertPersistentLobby(r.Context(), store, game, lobby); errors.Is(err, stores.ErrLobbyExists) {
+			util.ErrorAndAbort(w, r, http.StatusConflict, "lobby-exists")
+		} else if err != nil {
+			util.ErrorAndAbort(w, r, http.StatusInternalServerError, "", err)
+		}
+		info, err := store.GetLobby(r.Context(), game, code)
+		if err != nil {
+			util.ErrorAndAbort(w, r, http.StatusInternalServerError, "", err)
+		}
+		util.RenderJSON(w, r, http.StatusOK, info)
+	})
+
+	mux.HandleFunc("DELETE /v0/admin/games/{game}/lobbies/{code}", func(w http.ResponseWriter, r *http.Request) {
+		game, code := r.PathValue("game"), r.PathValue("code")
+		if !util.IsUUID(game) {
+			util.ErrorAndAbort(w, r, http.StatusBadRequest, "invalid-game")
+		}
+		if !util.IsValidLobbyCode(code) {
+			util.ErrorAndAbort(w, r, http.StatusBadRequest, "invalid-lobby-code")
+		}
+
+		err := store.UnsetPersistentLobby(r.Context(), game, code)
+		if errors.Is(err, stores.ErrNotFound) {
+			util.ErrorAndAbort(w, r, http.StatusNotFound, "lobby-not-found")
+		} else if err != nil {
+			util.ErrorAndAbort(w, r, http.StatusInternalServerError, "", err)
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(auth), []byte(token)) != 1 {
+			util.ErrorAndAbort(w, r, http.StatusUnauthorized, "")
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+// ensurePersistentLobbies creates the persistent lobbies of all configured games.
+func ensurePersistentLobbies(ctx context.Context, store stores.Store, games *gameconfig.Registry) {
+	logger := logging.GetLogger(ctx)
+	for game, config := range games.Games() {
+		for _, lobby := range config.PersistentLobbies {
+			if err := upsertPersistentLobby(ctx, store, game, lobby); err != nil {
+				logger.Error("failed to create persistent lobby", zap.Error(err), zap.String("game", game), zap.String("lobby", lobby.Code))
+			}
+		}
+	}
+}
+
+func upsertPersistentLobby(ctx context.Context, store stores.Store, game string, lobby gameconfig.PersistentLobby) error {
+	options := stores.LobbyOptions{
+		Public:      lobby.Public,
+		MaxPlayers:  lobby.MaxPlayers,
+		CanUpdateBy: &lobby.CanUpdateBy,
+		Password:    &lobby.Password,
+	}
+	if lobby.CustomData != nil {
+		options.CustomData = &lobby.CustomData
+	}
+	if err := store.UpsertPersistentLobby(ctx, game, lobby.Code, options); err != nil {
+		return fmt.Errorf("unable to upsert persistent lobby: %w", err)
+	}
+	return nil
+}
+
+func isValidCanUpdateBy(canUpdateBy string) bool {
+	switch canUpdateBy {
+	case "", stores.CanUpdateByCreator, stores.CanUpdateByLeader, stores.CanUpdateByAnyone, stores.CanUpdateByNone:
+		return true
+	}
+	return false
+}
@@ -2,27 +2,27 @@ package signaling
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"net/http"
 	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/coder/websocket"
 	"github.com/koenbollen/logging"
+	"github.com/poki/netlib/internal/auth"
 	"github.com/poki/netlib/internal/cloudflare"
+	"github.com/poki/netlib/internal/gameconfig"
 	"github.com/poki/netlib/internal/metrics"
+	"github.com/poki/netlib/internal/moderation"
 	"github.com/poki/netlib/internal/signaling/stores"
 	"github.com/poki/netlib/internal/util"
+	"github.com/poki/netlib/internal/wire"
 	"go.uber.org/zap"
 )
 
-const LobbyCleanInterval = 30 * time.Minute
-const LobbyCleanThreshold = 24 * time.Hour
-const peerPingDuration = 2 * time.Second
-const peerActiveUpdateInterval = 30 * time.Second
-
 // Countries to track states/regions for the avg-latency-at-Xs events.
 // United States
 // Canada
@@ -38,29 +38,111 @@ const peerActiveUpdateInterval = 30 * time.Second
 // Japan
 var countriesToTrackStates = []string{"US", "CA", "AU", "BR", "IN", "MX", "AR", "CL", "CN", "RU", "ID", "JP"}
 
-func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.CredentialsClient) (*sync.WaitGroup, http.HandlerFunc) {
+// HandlerOptions configures the signaling handler.
+type HandlerOptions struct {
+	RateLimits RateLimits
+	Games      *gameconfig.Registry
+	Identity   *auth.IdentityVerifier
+
+	// Moderation is used to block offensive lobby codes and customData, nil disables moderation.
+	Moderation *moderation.Filter
+
+	// AdminToken enables the admin API when set, see AdminHandler.
+	AdminToken string
+}
+
+func cleanEmptyLobbies(ctx context.Context, store stores.Store, config gameconfig.Config, game string, skipGames []string) {
+	logger := logging.GetLogger(ctx)
+
+	interval := time.Duration(config.LobbyCleanInterval)
+	if interval <= 0 {
+		interval = gameconfig.DefaultLobbyCleanInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			logger.Debug("cleaning empty lobbies", zap.String("game", game))
+			olderThan := util.NowUTC(ctx).Add(-time.Duration(config.LobbyCleanThreshold))
+			if err := store.CleanEmptyLobbies(ctx, olderThan, game, skipGames); err != nil {
+				logger.Error("failed to clean empty lobbies", zap.Error(err), zap.String("game", game))
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+var compressionModes = map[string]websocket.CompressionMode{
+	gameconfig.CompressionDisabled:          websocket.CompressionDisabled,
+	gameconfig.CompressionContextTakeover:   websocket.CompressionContextTakeover,
+	gameconfig.CompressionNoContextTakeover: websocket.CompressionNoContextTakeover,
+}
+
+// recordConnectionStats records how many bytes a connection sent and received, before and after
+// compression, and how long writing (and compressing) its packets took.
+func recordConnectionStats(ctx context.Context, peer *Peer, compression string, stats *wire.Stats) {
+	sent, sentWire := stats.Sent.Load(), stats.SentWire.Load()
+	ratio := 1.0
+	if sent > 0 {
+		ratio = float64(sentWire) / float64(sent)
+	}
+	go metrics.Record(ctx, "client", "connection-stats", peer.Game, peer.ID, peer.Lobby,
+		"version", peer.Version,
+		"compression", compression,
+		"messages", strconv.FormatInt(stats.Messages.Load(), 10),
+		"sent", strconv.FormatInt(sent, 10),
+		"sentWire", strconv.FormatInt(sentWire, 10),
+		"received", strconv.FormatInt(stats.Received.Load(), 10),
+		"receivedWire", strconv.FormatInt(stats.ReceivedWire.Load(), 10),
+		"ratio", strconv.FormatFloat(ratio, 'f', 3, 64),
+		"writeMicros", strconv.FormatInt(time.Duration(stats.WriteTime.Load()).Microseconds(), 10),
+	)
+}
+
+func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.CredentialsClient, options HandlerOptions) (*sync.WaitGroup, http.HandlerFunc) {
+	defaults := options.Games.Defaults()
+
 	manager := &TimeoutManager{
+		DisconnectThreshold: time.Duration(defaults.DisconnectThreshold),
+
 		Store: store,
 	}
 	go manager.Run(ctx)
 
+	limiter := newPacketRateLimiter(options.RateLimits)
 	go func() {
-		logger := logging.GetLogger(ctx)
-		ticker := time.NewTicker(LobbyCleanInterval)
+		ticker := time.NewTicker(rateLimitPruneInterval)
 		defer ticker.Stop()
 		for {
 			select {
 			case <-ticker.C:
-				logger.Debug("cleaning empty lobbies")
-				if err := store.CleanEmptyLobbies(ctx, util.NowUTC(ctx).Add(-LobbyCleanThreshold)); err != nil {
-					logger.Error("failed to clean empty lobbies", zap.Error(err))
-				}
+				limiter.Prune(util.NowUTC(ctx))
 			case <-ctx.Done():
 				return
 			}
 		}
 	}()
 
+	expirer := &LobbyExpirer{
+		Store: store,
+	}
+	go expirer.Run(ctx)
+
+	// Games with their own cleanup settings get their own cleaner, all
+	// other games are cleaned using the default settings.
+	var customGames []string
+	for game, config := range options.Games.Games() {
+		if config.LobbyCleanInterval != defaults.LobbyCleanInterval || config.LobbyCleanThreshold != defaults.LobbyCleanThreshold {
+			customGames = append(customGames, game)
+			go cleanEmptyLobbies(ctx, store, config, game, nil)
+		}
+	}
+	go cleanEmptyLobbies(ctx, store, defaults, "", customGames)
+
+	ensurePersistentLobbies(ctx, store, options.Games)
+
 	wg := &sync.WaitGroup{}
 	return wg, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		ctx := r.Context()
@@ -70,18 +152,37 @@ func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.Cre
 		ctx, cancel := context.WithCancel(ctx)
 		defer cancel()
 
+		// The game and version aren't known until hello, clients that want the compression
+		// settings of their game put them in the url.
+		config := options.Games.Get(r.URL.Query().Get("game"))
+		mode := config.CompressionFor(r.URL.Query().Get("version"))
+
+		stats := &wire.Stats{}
 		acceptOptions := &websocket.AcceptOptions{
-			InsecureSkipVerify: true, // Allow any origin/game to connect.
-			CompressionMode:    websocket.CompressionDisabled,
+			InsecureSkipVerify:   true, // Allow any origin/game to connect.
+			CompressionMode:      compressionModes[mode],
+			CompressionThreshold: config.CompressionThreshold,
+			Subprotocols:         wire.Subprotocols,
 		}
-		conn, err := websocket.Accept(w, r, acceptOptions)
+		conn, err := websocket.Accept(stats.ResponseWriter(w), r, acceptOptions)
 		if err != nil {
 			util.ErrorAndAbort(w, r, http.StatusBadRequest, "", err)
 		}
+		compression := "none"
+		if w.Header().Get("Sec-WebSocket-Extensions") != "" {
+			compression = mode
+		}
+
+		// Everything written to this connection uses the negotiated encoding.
+		codec := wire.ForSubprotocol(conn.Subprotocol())
+		ctx = wire.WithStats(wire.WithCodec(ctx, codec), stats)
 
 		wg.Add(1)
 		defer wg.Done()
 
+		connID := util.GenerateConnectionID(ctx)
+		ip := remoteIP(util.RemoteAddr(r))
+
 		country := r.Header.Get("CF-IPCountry")
 		region := r.Header.Get("X-Geo-Region")
 		if country == "" || region == "" {
@@ -95,33 +196,61 @@ func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.Cre
 		}
 
 		peer := &Peer{
-			store: store,
-			conn:  conn,
+			store:  store,
+			conn:   conn,
+			connID: connID,
+			codec:  codec,
+			stats:  stats,
 
 			retrievedIDCallback: manager.Reconnected,
 
+			games:      options.Games,
+			identity:   options.Identity,
+			moderation: options.Moderation,
+
 			Country: country,
 			Region:  region,
+			Origin:  r.Header.Get("Origin"),
 		}
 		defer func() {
 			logger.Debug("peer websocket closed", zap.String("peer", peer.ID), zap.String("game", peer.Game), zap.String("origin", r.Header.Get("Origin")))
 			conn.Close(websocket.StatusInternalError, "unexpected closure") // nolint:errcheck
 
-			if !peer.closedPacketReceived {
+			peer.clearClosedLobby()
+			if !peer.closedPacketReceived && !peer.sessionTakenOver.Load() {
 				// At this point ctx has already been cancelled, so we create a new one to use for the disconnect.
 				nctx, cancel := context.WithTimeout(logging.WithLogger(context.Background(), logger), time.Second*10)
 				defer cancel()
 				manager.Disconnected(nctx, peer)
 			}
+
+			if peer.ID != "" && stats.Messages.Load() > 0 {
+				recordConnectionStats(context.WithoutCancel(r.Context()), peer, compression, stats)
+			}
 		}()
 
 		go func() { // Sending ping packet every X to check if the tcp connection is still alive.
-			ticker := time.NewTicker(peerPingDuration)
+			// Until the peer said hello we don't know its game, so start with the default timings.
+			pingInterval := time.Duration(defaults.PingInterval)
+			activeUpdateInterval := time.Duration(defaults.ActiveUpdateInterval)
+			configured := false
+
+			ticker := time.NewTicker(pingInterval)
 			defer ticker.Stop()
 			var lastActiveUpdate time.Time
 			for {
 				select {
 				case <-ticker.C:
+					if !configured && peer.ID != "" {
+						config := options.Games.Get(peer.Game)
+						if time.Duration(config.PingInterval) != pingInterval {
+							pingInterval = time.Duration(config.PingInterval)
+							ticker.Reset(pingInterval)
+						}
+						activeUpdateInterval = time.Duration(config.ActiveUpdateInterval)
+						configured = true
+					}
+
 					if err := peer.Send(ctx, PingPacket{Type: "ping"}); err != nil {
 						if !util.ShouldIgnoreNetworkError(err) {
 							if strings.Contains(err.Error(), "write: broken pipe") || strings.Contains(err.Error(), "connection reset by peer") {
@@ -135,7 +264,7 @@ func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.Cre
 						// If the peer doesn't have an ID yet, it's still in the process of connecting, so we don't update it.
 						if peer.ID != "" {
 							now := util.NowUTC(ctx)
-							if lastActiveUpdate.IsZero() || now.Sub(lastActiveUpdate) >= peerActiveUpdateInterval {
+							if lastActiveUpdate.IsZero() || now.Sub(lastActiveUpdate) >= activeUpdateInterval {
 								manager.MarkPeerAsActive(ctx, peer.ID)
 								lastActiveUpdate = now
 							}
@@ -148,8 +277,11 @@ func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.Cre
 		}()
 
 		for ctx.Err() == nil {
-			var raw []byte
-			if _, raw, err = conn.Read(ctx); err != nil {
+			var msg wire.Message
+			if msg, err = wire.Read(ctx, conn); err != nil {
+				if !util.ShouldIgnoreNetworkError(err) {
+					err = util.ErrorWithCode(err, "invalid-packet")
+				}
 				util.ErrorAndDisconnect(ctx, conn, err)
 			}
 
@@ -157,8 +289,9 @@ func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.Cre
 				Type      string `json:"type"`
 				RequestID string `json:"rid"`
 			}{}
-			if err := json.Unmarshal(raw, &base); err != nil {
-				util.ErrorAndDisconnect(ctx, conn, err)
+			if err := msg.Decode(&base); err != nil {
+				util.ReplyError(ctx, conn, util.ErrorWithCode(fmt.Errorf("packets must be objects: %w", err), "invalid-packet"))
+				continue
 			}
 
 			// Use a local variable for the request-scoped context to avoid
@@ -168,6 +301,19 @@ func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.Cre
 				reqCtx = util.WithRequestID(reqCtx, base.RequestID)
 			}
 
+			// Before hello the connection is limited by itself, after it by its peer ID
+			// so reconnecting doesn't give a peer new buckets.
+			limitKey := "conn:" + connID
+			if peer.ID != "" {
+				limitKey = "peer:" + peer.ID
+			}
+			if !limiter.Allow(limitKey, ip, base.Type, util.NowUTC(reqCtx)) {
+				logger.Debug("rate limited packet", zap.String("peer", peer.ID), zap.String("type", base.Type), zap.String("ip", ip))
+				err := util.ErrorWithCode(fmt.Errorf("too many %s packets, slow down", base.Type), "rate-limited")
+				util.ReplyError(reqCtx, conn, err)
+				continue
+			}
+
 			if peer.closedPacketReceived {
 				if base.Type != "disconnect" && base.Type != "disconnected" { // expected lingering packets after closure.
 					logger.Warn("received packet after close", zap.String("peer", peer.ID), zap.String("type", base.Type))
@@ -179,7 +325,7 @@ func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.Cre
 			case "credentials":
 				credentials, err := cloudflare.GetCredentials(reqCtx)
 				if err != nil {
-					util.ReplyError(reqCtx, conn, err)
+					util.ReplyError(reqCtx, conn, util.ErrorWithCode(err, "credentials-unavailable"))
 				} else {
 					packet := CredentialsPacket{
 						Type:        "credentials",
@@ -193,8 +339,9 @@ func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.Cre
 
 			case "event":
 				params := metrics.EventParams{}
-				if err := json.Unmarshal(raw, &params); err != nil {
-					util.ErrorAndDisconnect(reqCtx, conn, err)
+				if err := msg.Decode(&params); err != nil {
+					util.ReplyError(reqCtx, conn, util.ErrorWithCode(fmt.Errorf("invalid event packet: %w", err), "invalid-packet"))
+					continue
 				}
 
 				// Add country and region to event data of the avg-latency-at-Xs events.
@@ -216,10 +363,16 @@ func Handler(ctx context.Context, store stores.Store, cloudflare *cloudflare.Cre
 				// ignore, ping/pong is just for the tcp keepalive.
 
 			default:
-				if err := peer.HandlePacket(reqCtx, base.Type, raw); err != nil {
+				if err := peer.HandlePacket(reqCtx, base.Type, msg); err != nil {
 					if err == ErrUnknownPacketType {
 						logger.Warn("unknown packet type received", zap.String("type", base.Type), zap.String("peer", peer.ID), zap.String("game", peer.Game), zap.String("origin", r.Header.Get("Origin")))
+						util.ReplyError(reqCtx, conn, err)
 					} else {
+						// Errors returned by handlers close the connection, errors that aren't coded
+						// are unexpected server errors.
+						if !util.HasErrorCode(err) {
+							err = util.ErrorWithCode(err, "internal-error")
+						}
 						util.ErrorAndDisconnect(reqCtx, conn, err)
 					}
 				}
@@ -0,0 +1,72 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/koenbollen/logging"
+	"github.com/poki/netlib/internal/metrics"
+	"github.com/poki/netlib/internal/signaling/stores"
+	"go.uber.org/zap"
+)
+
+const lobbyExpiryInterval = 10 * time.Second
+const lobbyExpiryBatchSize = 100
+
+// LobbyExpirer deletes lobbies that passed their ttl or idle timeout and
+// notifies the peers that were still in them.
+type LobbyExpirer struct {
+	Store stores.Store
+}
+
+func (expirer *LobbyExpirer) Run(ctx context.Context) {
+	for ctx.Err() == nil {
+		time.Sleep(lobbyExpiryInterval)
+		expirer.RunOnce(ctx)
+	}
+}
+
+func (expirer *LobbyExpirer) RunOnce(ctx context.Context) {
+	logger := logging.GetLogger(ctx)
+
+	for ctx.Err() == nil {
+		lobbies, err := expirer.Store.ClaimExpiredLobbies(ctx, lobbyExpiryBatchSize)
+		if err != nil {
+			logger.Error("failed to claim expired lobbies", zap.Error(err))
+			return
+		}
+
+		for _, lobby := range lobbies {
+			logger.Info("lobby expired", zap.String("game", lobby.Game), zap.String("lobby", lobby.Code), zap.String("reason", lobby.Reason))
+
+			if err := expirer.publishLobbyClosed(ctx, lobby); err != nil {
+				logger.Error("failed to publish lobby closed", zap.Error(err), zap.String("game", lobby.Game), zap.String("lobby", lobby.Code))
+			}
+
+			go metrics.Record(ctx, "lobby", "expired", lobby.Game, "", lobby.Code, "reason", lobby.Reason)
+		}
+
+		if len(lobbies) < lobbyExpiryBatchSize {
+			return
+		}
+	}
+}
+
+func (expirer *LobbyExpirer) publishLobbyClosed(ctx context.Context, lobby stores.ExpiredLobby) error {
+	if len(lobby.Peers) == 0 {
+		return nil
+	}
+
+	packet := LobbyClosedPacket{
+		Type:   "lobbyClosed",
+		Lobby:  lobby.Code,
+		Reason: lobby.Reason,
+	}
+	data, err := json.Marshal(packet)
+	if err != nil {
+		return err
+	}
+
+	return expirer.Store.Publish(ctx, lobby.Game+lobby.Code, data)
+}
@@ -0,0 +1,91 @@
+package signaling
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/poki/netlib/internal/moderation"
+	"github.com/poki/netlib/internal/util"
+)
+
+const (
+	CodeFormatDefault = "default"
+	CodeFormatShort   = "short"
+	CodeFormatNumeric = "numeric"
+	CodeFormatWords   = "words"
+)
+
+// maxCreateAttempts is how many codes are tried before creating a lobby fails.
+const maxCreateAttempts = 20
+
+// collisionsPerLength is the number of collisions after which generated codes
+// get one character (or digit) longer.
+const collisionsPerLength = 5
+
+type codeLengths struct {
+	min, def, max int
+}
+
+// lobbyCodeLengths are the allowed codeLength values per format. For words it's
+// the number of digits after the words. The default format has no length.
+var lobbyCodeLengths = map[string]codeLengths{
+	CodeFormatShort:   {min: 4, def: 4, max: 8},
+	CodeFormatNumeric: {min: 4, def: 6, max: 10},
+	CodeFormatWords:   {min: 1, def: 2, max: 3},
+}
+
+// knownCodeFormat returns format, or CodeFormatDefault when format is unknown. Clients of a newer
+// version can ask for formats this server doesn't have yet, they still get a lobby.
+func knownCodeFormat(format string) string {
+	if _, ok := lobbyCodeLengths[format]; !ok {
+		return CodeFormatDefault
+	}
+	return format
+}
+
+// checkCodeFormat returns an error when length can't be used to generate lobby codes in format.
+func checkCodeFormat(format string, length int) error {
+	lengths, ok := lobbyCodeLengths[format]
+	if !ok {
+		return nil
+	}
+	if length != 0 && (length < lengths.min || length > lengths.max) {
+		return util.ErrorWithCode(fmt.Errorf("codeLength must be between %d and %d for %s codes", lengths.min, lengths.max, format), "invalid-code-length")
+	}
+	return nil
+}
+
+// maxBlockedCodes is how many blocked codes are skipped before giving up on generating a clean code.
+const maxBlockedCodes = 10
+
+// generateLobbyCode generates a code in format for the given create attempt, skipping codes
+// that are blocked by filter. After every collisionsPerLength collisions codes get longer,
+// so finding a free code stays quick even when most codes of the requested length are taken.
+func generateLobbyCode(ctx context.Context, format string, length int, attempt int, filter *moderation.Filter) string {
+	code := generateCode(ctx, format, length, attempt)
+	for i := 0; i < maxBlockedCodes && filter.Blocked(code); i++ {
+		code = generateCode(ctx, format, length, attempt)
+	}
+	return code
+}
+
+func generateCode(ctx context.Context, format string, length int, attempt int) string {
+	lengths, ok := lobbyCodeLengths[format]
+	if !ok {
+		return util.GenerateLobbyCode(ctx)
+	}
+	if length == 0 {
+		length = lengths.def
+	}
+	length = min(length+attempt/collisionsPerLength, lengths.max)
+
+	switch format {
+	case CodeFormatShort:
+		return util.GenerateShortLobbyCode(ctx, length)
+	case CodeFormatNumeric:
+		return util.GenerateNumericLobbyCode(ctx, length)
+	case CodeFormatWords:
+		return util.GenerateWordLobbyCode(ctx, length)
+	}
+	return util.GenerateLobbyCode(ctx)
+}
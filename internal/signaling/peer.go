@@ -1,45 +1,99 @@
 package signaling
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/coder/websocket"
-	"github.com/coder/websocket/wsjson"
 	"github.com/koenbollen/logging"
+	"github.com/poki/netlib/internal/auth"
+	"github.com/poki/netlib/internal/gameconfig"
 	"github.com/poki/netlib/internal/metrics"
+	"github.com/poki/netlib/internal/moderation"
+	"github.com/poki/netlib/internal/signaling/schema"
 	"github.com/poki/netlib/internal/signaling/stores"
 	"github.com/poki/netlib/internal/util"
+	"github.com/poki/netlib/internal/wire"
 	"go.uber.org/zap"
 )
 
-const DefaultMaxPlayers = 4
+var ErrUnknownPacketType = util.ErrorWithCode(fmt.Errorf("unknown packet type"), "unknown-packet-type")
 
-var ErrUnknownPacketType = fmt.Errorf("unknown packet type")
+// Errors for packets that can't be handled in the current state of the peer, they're replied
+// to the client without closing the connection.
+var (
+	ErrPeerNotConnected   = util.ErrorWithCode(fmt.Errorf("peer not connected"), "peer-not-connected")
+	ErrAlreadyIntroduced  = util.ErrorWithCode(fmt.Errorf("peer already said hello"), "already-introduced")
+	ErrAlreadyInLobby     = util.ErrorWithCode(fmt.Errorf("already in a lobby"), "already-in-lobby")
+	ErrNotInLobby         = util.ErrorWithCode(fmt.Errorf("not in a lobby"), "not-in-lobby")
+	ErrInvalidCanUpdateBy = util.ErrorWithCode(fmt.Errorf("invalid canUpdateBy value"), "invalid-can-update-by")
+
+	// ErrInvalidSource closes the connection, peers can't send packets on behalf of other peers.
+	ErrInvalidSource = util.ErrorWithCode(fmt.Errorf("invalid source set"), "invalid-source")
+)
 
 type Peer struct {
-	store stores.Store
-	conn  *websocket.Conn
+	store  stores.Store
+	conn   *websocket.Conn
+	connID string
+	codec  wire.Codec
+	stats  *wire.Stats
 
 	closedPacketReceived bool
 
+	// sessionTakenOver is set when another connection took over this peer, this
+	// connection is closed then without marking the peer as disconnected.
+	sessionTakenOver atomic.Bool
+	sessionStartedAt time.Time
+
 	retrievedIDCallback func(context.Context, string, string, string) (bool, []string, error)
 
+	games      *gameconfig.Registry
+	config     gameconfig.Config
+	identity   *auth.IdentityVerifier
+	moderation *moderation.Filter
+
 	ID      string
 	Secret  string
 	Game    string
 	Lobby   string
 	Country string
 	Region  string
+	Origin  string
+
+	UserID      string
+	DisplayName string
+
+	// Version is the version of the client library, as sent in hello.
+	Version string
+
+	// capabilities are the protocol features negotiated in hello, see Has.
+	capabilities map[string]bool
+
+	// closedLobby is set when the server closed a lobby of this peer, see clearClosedLobby.
+	closedLobby atomic.Pointer[string]
 }
 
 func (p *Peer) Send(ctx context.Context, packet any) error {
-	return wsjson.Write(ctx, p.conn, packet)
+	return wire.Write(p.wireContext(ctx), p.conn, packet)
 }
 
-func (p *Peer) RequestConnection(ctx context.Context, otherID string) error {
+// wireContext returns ctx with the encoding and stats of the connection of this peer, so
+// packets can also be written from callbacks that don't run with the context of the connection.
+func (p *Peer) wireContext(ctx context.Context) context.Context {
+	return wire.WithStats(wire.WithCodec(ctx, p.codec), p.stats)
+}
+
+// RequestConnection tells this peer and otherID to connect to each other. peerInfo is the
+// public information of the lobby members, it's included so both know who they connect to.
+func (p *Peer) RequestConnection(ctx context.Context, otherID string, peerInfo map[string]stores.PeerInfo) error {
 	toMe := ConnectPacket{
 		Type:   "connect",
 		ID:     otherID,
@@ -50,8 +104,14 @@ func (p *Peer) RequestConnection(ctx context.Context, otherID string) error {
 		ID:     p.ID,
 		Polite: false,
 	}
+	if info, ok := peerInfo[otherID]; ok {
+		toMe.Peer = &info
+	}
+	if info, ok := peerInfo[p.ID]; ok {
+		toThem.Peer = &info
+	}
 
-	err := wsjson.Write(ctx, p.conn, toMe)
+	err := p.Send(ctx, toMe)
 	if err != nil {
 		return err
 	}
@@ -61,7 +121,7 @@ func (p *Peer) RequestConnection(ctx context.Context, otherID string) error {
 		return err
 	}
 
-	err = p.store.Publish(ctx, p.Game+p.Lobby+otherID, data)
+	_, err = p.store.PublishToPeer(ctx, p.Game, p.Lobby, otherID, data)
 	if err != nil {
 		return err
 	}
@@ -76,22 +136,65 @@ func (p *Peer) ForwardMessage(ctx context.Context, raw []byte) {
 	logger := logging.GetLogger(ctx)
 	ctx, cancel := context.WithTimeout(ctx, 1*time.Minute)
 	defer cancel()
-	err := p.conn.Write(ctx, websocket.MessageText, raw)
+	err := wire.WriteJSON(p.wireContext(ctx), p.conn, raw)
 	if err != nil && !util.ShouldIgnoreNetworkError(err) {
 		logger.Warn("failed to forward message", zap.Error(err))
 	}
 }
 
-func (p *Peer) HandlePacket(ctx context.Context, typ string, raw []byte) error {
+// subscribeLobby forwards the messages of p.Lobby to this connection until the lobby is closed
+// or the connection ends.
+func (p *Peer) subscribeLobby(ctx context.Context) {
+	lobby := p.Lobby
+	ctx, unsubscribe := context.WithCancel(ctx)
+	p.store.Subscribe(ctx, func(ctx context.Context, raw []byte) {
+		p.ForwardMessage(ctx, raw)
+		if isLobbyClosed(raw) {
+			// p.Lobby is only changed by the goroutine that handles packets, it's cleared there.
+			p.closedLobby.Store(&lobby)
+			unsubscribe()
+		}
+	}, p.Game, lobby, p.ID)
+}
+
+// enableEventLog makes the server log the events of p.Lobby when the peer can replay them,
+// lobbies without such peers don't keep a log.
+func (p *Peer) enableEventLog(ctx context.Context) error {
+	if !p.Has(CapabilityReplay) {
+		return nil
+	}
+	return p.store.EnableLobbyEventLog(ctx, p.Game, p.Lobby)
+}
+
+// clearClosedLobby removes the peer from its lobby when the server closed it.
+func (p *Peer) clearClosedLobby() {
+	if closed := p.closedLobby.Swap(nil); closed != nil && *closed == p.Lobby {
+		p.Lobby = ""
+	}
+}
+
+func isLobbyClosed(raw []byte) bool {
+	if !bytes.Contains(raw, []byte(`"lobbyClosed"`)) {
+		return false
+	}
+	var packet struct {
+		Type string `json:"type"`
+	}
+	return json.Unmarshal(raw, &packet) == nil && packet.Type == "lobbyClosed"
+}
+
+func (p *Peer) HandlePacket(ctx context.Context, typ string, msg wire.Message) error {
 	logger := logging.GetLogger(ctx).With(zap.String("peer", p.ID))
-	logger.Debug("handling packet", zap.String("type", typ), zap.ByteString("data", raw))
+	logger.Debug("handling packet", zap.String("type", typ), zap.ByteString("data", msg.Data))
+
+	p.clearClosedLobby()
 
 	var err error
 	switch typ {
 	case "hello":
 		packet := HelloPacket{}
-		if err := json.Unmarshal(raw, &packet); err != nil {
-			return fmt.Errorf("unable to unmarshal json: %w", err)
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
 		}
 		err = p.HandleHelloPacket(ctx, packet)
 		if err != nil {
@@ -100,8 +203,8 @@ func (p *Peer) HandlePacket(ctx context.Context, typ string, raw []byte) error {
 
 	case "close":
 		packet := ClosePacket{}
-		if err := json.Unmarshal(raw, &packet); err != nil {
-			return fmt.Errorf("unable to unmarshal json: %w", err)
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
 		}
 		err = p.HandleClosePacket(ctx, packet)
 		if err != nil {
@@ -110,8 +213,8 @@ func (p *Peer) HandlePacket(ctx context.Context, typ string, raw []byte) error {
 
 	case "leave":
 		packet := LeavePacket{}
-		if err := json.Unmarshal(raw, &packet); err != nil {
-			return fmt.Errorf("unable to unmarshal json: %w", err)
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
 		}
 		err = p.HandleLeaveLobbyPacket(ctx, packet)
 		if err != nil {
@@ -120,8 +223,8 @@ func (p *Peer) HandlePacket(ctx context.Context, typ string, raw []byte) error {
 
 	case "list":
 		packet := ListPacket{}
-		if err := json.Unmarshal(raw, &packet); err != nil {
-			return fmt.Errorf("unable to unmarshal json: %w", err)
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
 		}
 		err = p.HandleListPacket(ctx, packet)
 		if err != nil {
@@ -130,8 +233,8 @@ func (p *Peer) HandlePacket(ctx context.Context, typ string, raw []byte) error {
 
 	case "create":
 		packet := CreatePacket{}
-		if err := json.Unmarshal(raw, &packet); err != nil {
-			return fmt.Errorf("unable to unmarshal json: %w", err)
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
 		}
 		err = p.HandleCreatePacket(ctx, packet)
 		if err != nil {
@@ -140,8 +243,8 @@ func (p *Peer) HandlePacket(ctx context.Context, typ string, raw []byte) error {
 
 	case "join":
 		packet := JoinPacket{}
-		if err := json.Unmarshal(raw, &packet); err != nil {
-			return fmt.Errorf("unable to unmarshal json: %w", err)
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
 		}
 		err = p.HandleJoinPacket(ctx, packet)
 		if err != nil {
@@ -150,30 +253,105 @@ func (p *Peer) HandlePacket(ctx context.Context, typ string, raw []byte) error {
 
 	case "lobbyUpdate":
 		packet := LobbyUpdatePacket{}
-		if err := json.Unmarshal(raw, &packet); err != nil {
-			return fmt.Errorf("unable to unmarshal json: %w", err)
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
 		}
 		err = p.HandleUpdatePacket(ctx, packet)
 		if err != nil {
 			return fmt.Errorf("unable to handle packet: %w", err)
 		}
 
+	case "peerUpdate":
+		packet := PeerUpdatePacket{}
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
+		}
+		err = p.HandlePeerUpdatePacket(ctx, packet)
+		if err != nil {
+			return fmt.Errorf("unable to handle packet: %w", err)
+		}
+
+	case "ready":
+		packet := ReadyPacket{}
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
+		}
+		err = p.HandleReadyPacket(ctx, packet)
+		if err != nil {
+			return fmt.Errorf("unable to handle packet: %w", err)
+		}
+
+	case "setState":
+		packet := SetStatePacket{}
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
+		}
+		err = p.HandleSetStatePacket(ctx, packet)
+		if err != nil {
+			return fmt.Errorf("unable to handle packet: %w", err)
+		}
+
+	case "getState":
+		packet := GetStatePacket{}
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
+		}
+		err = p.HandleGetStatePacket(ctx, packet)
+		if err != nil {
+			return fmt.Errorf("unable to handle packet: %w", err)
+		}
+
+	case "ack":
+		packet := AckPacket{}
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
+		}
+		err = p.HandleAckPacket(ctx, packet)
+		if err != nil {
+			return fmt.Errorf("unable to handle packet: %w", err)
+		}
+
+	case "createTransferToken":
+		packet := CreateTransferTokenPacket{}
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
+		}
+		err = p.HandleCreateTransferTokenPacket(ctx, packet)
+		if err != nil {
+			return fmt.Errorf("unable to handle packet: %w", err)
+		}
+
+	case "replay":
+		packet := ReplayPacket{}
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
+		}
+		err = p.HandleReplayPacket(ctx, packet)
+		if err != nil {
+			return fmt.Errorf("unable to handle packet: %w", err)
+		}
+
 	case "connected": // TODO: Do we want to keep track of connections between peers?
 	case "disconnected": // TODO: Do we want to keep track of connections between peers?
 
 	case "candidate":
-		fallthrough
-	case "description":
-		routing := ForwardablePacket{}
-		if err := json.Unmarshal(raw, &routing); err != nil {
-			util.ErrorAndDisconnect(ctx, p.conn, err)
+		packet := CandidatePacket{}
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
+		}
+		err = p.forwardToPeer(ctx, packet.Source, packet.Recipient, msg)
+		if err != nil {
+			return fmt.Errorf("unable to handle packet: %w", err)
 		}
-		if routing.Source != p.ID {
-			util.ErrorAndDisconnect(ctx, p.conn, fmt.Errorf("invalid source set"))
+
+	case "description":
+		packet := DescriptionPacket{}
+		if !p.decodePacket(ctx, typ, msg, &packet) {
+			return nil
 		}
-		err = p.store.Publish(ctx, p.Game+p.Lobby+routing.Recipient, raw)
+		err = p.forwardToPeer(ctx, packet.Source, packet.Recipient, msg)
 		if err != nil {
-			return fmt.Errorf("unable to publish packet to forward: %w", err)
+			return fmt.Errorf("unable to handle packet: %w", err)
 		}
 
 	default:
@@ -183,13 +361,129 @@ func (p *Peer) HandlePacket(ctx context.Context, typ string, raw []byte) error {
 	return nil
 }
 
+// decodePacket decodes msg into packet and validates it against the schema of its type.
+// Invalid packets get an error reply and false is returned, the connection stays open.
+func (p *Peer) decodePacket(ctx context.Context, typ string, msg wire.Message, packet any) bool {
+	err := msg.Decode(packet)
+	if err != nil {
+		err = schema.DecodeError(typ, err)
+	} else {
+		err = schema.Validate(typ, packet)
+	}
+	if err != nil {
+		logging.GetLogger(ctx).Info("invalid packet received", zap.String("peer", p.ID), zap.String("type", typ), zap.Error(err))
+		util.ReplyError(ctx, p.conn, err)
+		return false
+	}
+	return true
+}
+
+// forwardToPeer publishes a candidate or description packet to its recipient. The packet is
+// forwarded as the client sent it, only a msgSeq set by the sender is removed as the server
+// sets its own.
+func (p *Peer) forwardToPeer(ctx context.Context, source, recipient string, msg wire.Message) error {
+	if source != p.ID {
+		util.ErrorAndDisconnect(ctx, p.conn, ErrInvalidSource)
+		return nil
+	}
+	data, err := msg.JSON()
+	if err != nil {
+		return err
+	}
+	if data, err = withoutProperty(data, "msgSeq"); err != nil {
+		return err
+	}
+	if _, err := p.store.PublishToPeer(ctx, p.Game, p.Lobby, recipient, data); err != nil {
+		return fmt.Errorf("unable to publish packet to forward: %w", err)
+	}
+	return nil
+}
+
+// withoutProperty removes the property name from the json object in data. Objects without
+// the property are returned as is.
+func withoutProperty(data []byte, name string) ([]byte, error) {
+	if !bytes.Contains(data, []byte(`"`+name+`"`)) {
+		return data, nil
+	}
+	var object map[string]json.RawMessage
+	if err := json.Unmarshal(data, &object); err != nil {
+		return nil, err
+	}
+	if _, ok := object[name]; !ok {
+		return data, nil
+	}
+	delete(object, name)
+	return json.Marshal(object)
+}
+
 func (p *Peer) HandleHelloPacket(ctx context.Context, packet HelloPacket) error {
 	logger := logging.GetLogger(ctx)
 	if p.Game != "" {
-		return fmt.Errorf("already introduced %s for game %s", p.ID, p.Game)
+		util.ReplyError(ctx, p.conn, ErrAlreadyIntroduced)
+		return nil
 	}
 	if !util.IsUUID(packet.Game) {
-		return fmt.Errorf("no game id supplied")
+		util.ReplyError(ctx, p.conn, util.ErrorWithCode(fmt.Errorf("invalid game id %q", packet.Game), "invalid-game"))
+		return nil
+	}
+	config := p.games.Get(packet.Game)
+	if err := config.CheckPeerCustomData(packet.PeerCustomData); err != nil {
+		util.ReplyError(ctx, p.conn, err)
+		return nil
+	}
+	if err := p.moderateCustomData(config, "peerCustomData", packet.PeerCustomData); err != nil {
+		util.ReplyError(ctx, p.conn, err)
+		return nil
+	}
+
+	// Rejected hellos return nil, just like a failed reconnect below. The peer stays connected
+	// but can't do anything until it sends a valid hello.
+	if err := config.CheckClientVersion(packet.Version); err != nil {
+		logger.Info("peer rejected, client too old", zap.String("game", packet.Game), zap.String("version", packet.Version))
+		util.ReplyError(ctx, p.conn, err)
+		return nil
+	}
+	if !config.AllowsOrigin(p.Origin) {
+		logger.Info("peer rejected, origin not allowed", zap.String("game", packet.Game), zap.String("origin", p.Origin))
+		util.ReplyError(ctx, p.conn, util.ErrorWithCode(fmt.Errorf("origin not allowed for this game"), "origin-not-allowed"))
+		return nil
+	}
+	var identity auth.Identity
+	if config.SigningKey != "" {
+		claims, err := p.verifyGameToken(ctx, packet, config)
+		if err != nil {
+			logger.Info("peer rejected, invalid token", zap.String("game", packet.Game), zap.Error(err))
+			util.ReplyError(ctx, p.conn, err)
+			return nil
+		}
+		identity.UserID = claims.Subject
+	}
+	if packet.IdentityToken != "" {
+		if p.identity == nil {
+			util.ReplyError(ctx, p.conn, util.ErrorWithCode(fmt.Errorf("identity tokens are not supported by this server"), "identity-not-supported"))
+			return nil
+		}
+		var err error
+		identity, err = p.identity.Verify(packet.IdentityToken, util.NowUTC(ctx))
+		if err != nil {
+			logger.Info("peer rejected, invalid identity token", zap.String("game", packet.Game), zap.Error(err))
+			util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "invalid-identity-token"))
+			return nil
+		}
+	}
+
+	if packet.TransferToken != "" {
+		secret := util.GenerateSecret(ctx)
+		peerID, err := p.store.ClaimTransferToken(ctx, packet.Game, packet.TransferToken, secret)
+		if errors.Is(err, stores.ErrInvalidTransferToken) {
+			logger.Info("peer failed taking over session", zap.String("game", packet.Game), zap.Error(err))
+			util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "invalid-transfer-token"))
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to claim transfer token: %w", err)
+		}
+		packet.ID = peerID
+		packet.Secret = secret
 	}
 
 	hasReconnected := false
@@ -215,16 +509,43 @@ func (p *Peer) HandleHelloPacket(ctx context.Context, packet HelloPacket) error
 		}
 
 		p.Game = packet.Game
+		p.Version = packet.Version
+		p.capabilities = negotiateCapabilities(packet.Capabilities)
 		p.ID = packet.ID
 		p.Secret = packet.Secret
+		p.config = config
 	} else {
+		id := util.GeneratePeerID(ctx)
+		secret := util.GenerateSecret(ctx)
+		if err := p.store.CreatePeer(ctx, id, secret, packet.Game, time.Duration(config.DisconnectThreshold), config.MaxPeers); err == stores.ErrTooManyPeers {
+			logger.Info("peer rejected, too many peers", zap.String("game", packet.Game), zap.Int("maxPeers", config.MaxPeers))
+			util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "too-many-peers"))
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("unable to create peer: %w", err)
+		}
+
 		p.Game = packet.Game
-		p.ID = util.GeneratePeerID(ctx)
-		p.Secret = util.GenerateSecret(ctx)
+		p.Version = packet.Version
+		p.capabilities = negotiateCapabilities(packet.Capabilities)
+		p.config = config
+		p.ID = id
+		p.Secret = secret
 		logger.Debug("peer connecting", zap.String("game", p.Game), zap.String("peer", p.ID), zap.String("version", packet.Version))
+	}
 
-		if err := p.store.CreatePeer(ctx, p.ID, p.Secret, p.Game); err != nil {
-			return fmt.Errorf("unable to create peer: %w", err)
+	// A peer can only have one connection. Reconnects and transfers take over the peer from
+	// connections that are still open, those are closed on whatever instance they are.
+	p.sessionStartedAt = util.NowUTC(ctx)
+	p.store.SubscribeSession(ctx, p.handleSessionMessage, p.Game, p.ID)
+	if packet.TransferToken != "" {
+		logger.Info("peer session transferred", zap.String("game", p.Game), zap.String("peer", p.ID))
+		if err := p.publishSessionTakeover(ctx, "session-transferred"); err != nil {
+			return fmt.Errorf("unable to publish session takeover: %w", err)
+		}
+	} else if hasReconnected {
+		if err := p.publishSessionTakeover(ctx, "session-replaced"); err != nil {
+			return fmt.Errorf("unable to publish session takeover: %w", err)
 		}
 	}
 
@@ -234,23 +555,68 @@ func (p *Peer) HandleHelloPacket(ctx context.Context, packet HelloPacket) error
 		}
 	}
 
+	// Only messages to peers that ack are kept in their outbox, a reconnecting peer might not ack anymore.
+	acks := p.Has(CapabilityAcks)
+	if acks || hasReconnected {
+		if err := p.store.UpdatePeerAcks(ctx, p.ID, acks); err != nil {
+			return fmt.Errorf("unable to update peer acks: %w", err)
+		}
+	}
+
+	if packet.PeerCustomData != nil {
+		if err := p.store.UpdatePeerCustomData(ctx, p.ID, packet.PeerCustomData); err != nil {
+			return fmt.Errorf("unable to update peer custom data: %w", err)
+		}
+	}
+
+	// Reconnecting peers without a token keep the identity they connected with.
+	if identity.UserID != "" {
+		p.UserID = identity.UserID
+		p.DisplayName = identity.DisplayName
+		if err := p.store.UpdatePeerIdentity(ctx, p.ID, p.UserID, p.DisplayName); err != nil {
+			return fmt.Errorf("unable to update peer identity: %w", err)
+		}
+	}
+
 	err := p.Send(ctx, WelcomePacket{
 		Type:   "welcome",
 		ID:     p.ID,
 		Secret: p.Secret,
+
+		UserID:      p.UserID,
+		DisplayName: p.DisplayName,
+
+		ProtocolVersion: ProtocolVersion,
+		Capabilities:    Capabilities,
 	})
 	if err != nil {
 		return err
 	}
 
 	if hasReconnected {
+		since := packet.Since
+		if since != nil && len(reconnectingLobbies) > 1 {
+			// The sequence numbers are per lobby, since can't be right for all of them.
+			err := fmt.Errorf("since can't be used when rejoining %d lobbies", len(reconnectingLobbies))
+			util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "ambiguous-since"))
+			since = nil
+		}
 		for _, lobbyID := range reconnectingLobbies {
 			logger.Debug("peer rejoining lobby", zap.String("game", p.Game), zap.String("peer", p.ID), zap.String("lobby", p.Lobby), zap.String("version", packet.Version))
 			p.Lobby = lobbyID
-			p.store.Subscribe(ctx, p.ForwardMessage, p.Game, p.Lobby, p.ID)
+			p.subscribeLobby(ctx)
+			if err := p.enableEventLog(ctx); err != nil {
+				return err
+			}
 
 			go metrics.Record(ctx, "client", "reconnected", p.Game, p.ID, p.Lobby, "version", packet.Version)
 
+			if since != nil {
+				if err := p.replay(ctx, "", *since); err != nil {
+					return err
+				}
+			}
+
 			// We just reconnected, and we might be the only peer in the lobby.
 			// So do an election to make sure we then become the leader.
 			// This won't do anything if there's already a leader.
@@ -277,6 +643,12 @@ func (p *Peer) HandleHelloPacket(ctx context.Context, packet HelloPacket) error
 				}
 			}
 		}
+
+		if acks {
+			if err := p.resendUnackedMessages(ctx); err != nil {
+				return err
+			}
+		}
 	} else {
 		go metrics.Record(ctx, "client", "connected", p.Game, p.ID, p.Lobby, "version", packet.Version)
 	}
@@ -284,6 +656,24 @@ func (p *Peer) HandleHelloPacket(ctx context.Context, packet HelloPacket) error
 	return nil
 }
 
+// verifyGameToken verifies the token in hello was signed with the key of the game.
+func (p *Peer) verifyGameToken(ctx context.Context, packet HelloPacket, config gameconfig.Config) (auth.Claims, error) {
+	var claims auth.Claims
+	if packet.Token == "" {
+		return claims, util.ErrorWithCode(fmt.Errorf("this game requires a signed token"), "token-required")
+	}
+	if err := auth.Verify(packet.Token, auth.HMACKey(config.SigningKey), util.NowUTC(ctx), &claims); err != nil {
+		if errors.Is(err, auth.ErrExpiredToken) {
+			return claims, util.ErrorWithCode(err, "token-expired")
+		}
+		return claims, util.ErrorWithCode(err, "invalid-token")
+	}
+	if !strings.EqualFold(claims.Game, packet.Game) {
+		return claims, util.ErrorWithCode(fmt.Errorf("token is not signed for this game"), "invalid-token")
+	}
+	return claims, nil
+}
+
 func (p *Peer) HandleClosePacket(ctx context.Context, packet ClosePacket) error {
 	logger := logging.GetLogger(ctx)
 	go metrics.Record(ctx, "client", "close", p.Game, p.ID, p.Lobby)
@@ -298,7 +688,7 @@ func (p *Peer) HandleClosePacket(ctx context.Context, packet ClosePacket) error
 	)
 
 	if p.Lobby != "" {
-		err := p.store.LeaveLobby(ctx, p.Game, p.Lobby, p.ID)
+		wasReady, err := p.store.LeaveLobby(ctx, p.Game, p.Lobby, p.ID)
 		if err != nil {
 			return fmt.Errorf("unable to leave lobby: %w", err)
 		}
@@ -308,11 +698,14 @@ func (p *Peer) HandleClosePacket(ctx context.Context, packet ClosePacket) error
 		}
 		data, err := json.Marshal(packet)
 		if err == nil {
-			err := p.store.Publish(ctx, p.Game+p.Lobby, data)
+			err := p.publishToLobby(ctx, data)
 			if err != nil {
 				logger.Error("failed to publish disconnect packet", zap.Error(err))
 			}
 		}
+		if err := publishReadyAfterRemoval(ctx, p.store, p.Game, p.Lobby, p.ID, wasReady); err != nil {
+			return err
+		}
 
 		_, err = p.doLeaderElectionAndPublish(ctx)
 		if err != nil {
@@ -329,23 +722,28 @@ func (p *Peer) HandleLeaveLobbyPacket(ctx context.Context, packet LeavePacket) e
 	logger := logging.GetLogger(ctx)
 
 	if p.ID == "" {
-		return fmt.Errorf("peer not connected")
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
 	}
 	if p.Lobby == "" {
-		return fmt.Errorf("not in a lobby")
+		util.ReplyError(ctx, p.conn, ErrNotInLobby)
+		return nil
 	}
 
-	err := p.store.LeaveLobby(ctx, p.Game, p.Lobby, p.ID)
+	wasReady, err := p.store.LeaveLobby(ctx, p.Game, p.Lobby, p.ID)
 	if err != nil {
 		return err
 	}
 	disc := DisconnectPacket{Type: "disconnect", ID: p.ID}
 	data, err := json.Marshal(disc)
 	if err == nil {
-		if err := p.store.Publish(ctx, p.Game+p.Lobby, data); err != nil {
+		if err := p.publishToLobby(ctx, data); err != nil {
 			logger.Error("failed to publish disconnect packet", zap.Error(err))
 		}
 	}
+	if err := publishReadyAfterRemoval(ctx, p.store, p.Game, p.Lobby, p.ID, wasReady); err != nil {
+		return err
+	}
 	_, err = p.doLeaderElectionAndPublish(ctx)
 	if err != nil {
 		return err
@@ -358,9 +756,14 @@ func (p *Peer) HandleLeaveLobbyPacket(ctx context.Context, packet LeavePacket) e
 
 func (p *Peer) HandleListPacket(ctx context.Context, packet ListPacket) error {
 	if p.ID == "" {
-		return fmt.Errorf("peer not connected")
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
+	}
+	if !p.config.PublicListing {
+		util.ReplyError(ctx, p.conn, util.ErrorWithCode(fmt.Errorf("listing lobbies is disabled for this game"), "public-listing-disabled"))
+		return nil
 	}
-	lobbies, err := p.store.ListLobbies(ctx, p.Game, p.Country, p.Region, packet.Filter, packet.Sort, packet.Limit)
+	lobbies, err := p.store.ListLobbies(ctx, p.Game, p.Country, p.Region, packet.Filter, packet.Sort, packet.Limit, packet.IncludeInGame)
 	if err != nil {
 		return err
 	}
@@ -377,10 +780,12 @@ func (p *Peer) HandleListPacket(ctx context.Context, packet ListPacket) error {
 func (p *Peer) HandleCreatePacket(ctx context.Context, packet CreatePacket) error {
 	logger := logging.GetLogger(ctx)
 	if p.ID == "" {
-		return fmt.Errorf("peer not connected")
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
 	}
 	if p.Lobby != "" {
-		return fmt.Errorf("already in a lobby %s:%s as %s", p.Game, p.Lobby, p.ID)
+		util.ReplyError(ctx, p.conn, ErrAlreadyInLobby)
+		return nil
 	}
 
 	if packet.CanUpdateBy == "" {
@@ -390,27 +795,52 @@ func (p *Peer) HandleCreatePacket(ctx context.Context, packet CreatePacket) erro
 			packet.CanUpdateBy != stores.CanUpdateByLeader &&
 			packet.CanUpdateBy != stores.CanUpdateByAnyone &&
 			packet.CanUpdateBy != stores.CanUpdateByNone {
-			return fmt.Errorf("invalid canUpdateBy value")
+			util.ReplyError(ctx, p.conn, ErrInvalidCanUpdateBy)
+			return nil
 		}
 	}
 
-	maxPlayers := DefaultMaxPlayers
+	maxPlayers := p.config.DefaultMaxPlayers
 	if packet.MaxPlayers != nil {
 		maxPlayers = *packet.MaxPlayers
 	}
+	packet.CodeFormat = knownCodeFormat(packet.CodeFormat)
+
+	if err := p.checkCreatePacket(packet, maxPlayers); err != nil {
+		util.ReplyError(ctx, p.conn, err)
+		return nil
+	}
+	if packet.PeerCustomData != nil {
+		if err := p.config.CheckPeerCustomData(packet.PeerCustomData); err != nil {
+			util.ReplyError(ctx, p.conn, err)
+			return nil
+		}
+		if err := p.moderateCustomData(p.config, "peerCustomData", packet.PeerCustomData); err != nil {
+			util.ReplyError(ctx, p.conn, err)
+			return nil
+		}
+	}
+
+	var expiresAt *time.Time
+	if packet.TTL > 0 {
+		t := util.NowUTC(ctx).Add(time.Duration(packet.TTL) * time.Second)
+		expiresAt = &t
+	}
+	idleTimeout := time.Duration(packet.IdleTimeout) * time.Second
+	reconnectPolicy := packet.ReconnectPolicy
+	if reconnectPolicy == "" {
+		reconnectPolicy = stores.ReconnectPolicyHold
+	}
 
 	attempts := 0
-	for ; attempts < 20; attempts++ {
-		if packet.CodeFormat == "short" && attempts < 19 {
-			// The first 15 attempts will try to generate a 4 character code.
-			// After that, it will try to generate a 5 character code.
-			length := 4
-			if attempts > 15 {
-				length = 5
-			}
-			p.Lobby = util.GenerateShortLobbyCode(ctx, length)
+	for ; attempts < maxCreateAttempts; attempts++ {
+		if packet.Code != "" {
+			p.Lobby = packet.Code
 		} else {
-			p.Lobby = util.GenerateLobbyCode(ctx)
+			p.Lobby = generateLobbyCode(ctx, packet.CodeFormat, packet.CodeLength, attempts, p.moderation)
+			if p.moderation.Blocked(p.Lobby) {
+				continue
+			}
 		}
 
 		err := p.store.CreateLobby(ctx, p.Game, p.Lobby, p.ID, stores.LobbyOptions{
@@ -419,23 +849,55 @@ func (p *Peer) HandleCreatePacket(ctx context.Context, packet CreatePacket) erro
 			CanUpdateBy: &packet.CanUpdateBy,
 			Password:    &packet.Password,
 			MaxPlayers:  &maxPlayers,
+			ExpiresAt:   expiresAt,
+			IdleTimeout: &idleTimeout,
+
+			ReconnectPolicy: &reconnectPolicy,
+
+			ValidateCustomData: p.config.ValidateCustomData,
+			MaxLobbies:         p.config.MaxLobbies,
 		})
 		if err != nil {
-			if err == stores.ErrLobbyExists {
+			if err == stores.ErrTooManyLobbies {
+				p.Lobby = ""
+				util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "too-many-lobbies"))
+				return nil
+			} else if err == stores.ErrLobbyExists {
+				if packet.Code != "" {
+					p.Lobby = ""
+					util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "lobby-exists"))
+					return nil
+				}
 				continue
 			} else if err == stores.ErrInvalidPassword {
 				util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "invalid-password"))
 				return nil
+			} else if util.HasErrorCode(err) {
+				p.Lobby = ""
+				util.ReplyError(ctx, p.conn, err)
+				return nil
 			}
 			return err
 		}
 		break
 	}
-	if attempts == 20 {
-		return fmt.Errorf("unable to create lobby, too many attempts to find a unique code")
+	if attempts == maxCreateAttempts {
+		p.Lobby = ""
+		util.ReplyError(ctx, p.conn, util.ErrorWithCode(fmt.Errorf("unable to create lobby, too many attempts to find a unique code"), "lobby-code-unavailable"))
+		return nil
+	}
+
+	// The customData of the peer is only stored once the lobby exists, a failed create doesn't change it.
+	if packet.PeerCustomData != nil {
+		if err := p.store.UpdatePeerCustomData(ctx, p.ID, packet.PeerCustomData); err != nil {
+			return fmt.Errorf("unable to update peer custom data: %w", err)
+		}
 	}
 
-	p.store.Subscribe(ctx, p.ForwardMessage, p.Game, p.Lobby, p.ID)
+	p.subscribeLobby(ctx)
+	if err := p.enableEventLog(ctx); err != nil {
+		return err
+	}
 
 	lobby, err := p.store.GetLobby(ctx, p.Game, p.Lobby)
 	if err != nil {
@@ -445,27 +907,49 @@ func (p *Peer) HandleCreatePacket(ctx context.Context, packet CreatePacket) erro
 	logger.Debug("created lobby", zap.String("game", p.Game), zap.String("lobby", p.Lobby), zap.String("peer", p.ID))
 	go metrics.Record(ctx, "lobby", "created", p.Game, p.ID, p.Lobby)
 
-	return p.Send(ctx, JoinedPacket{
-		RequestID: packet.RequestID,
-		Type:      "joined",
-		LobbyCode: p.Lobby, // backwards compatibility
-		LobbyInfo: lobby,
-	})
+	return p.Send(ctx, p.joinedPacket(packet.RequestID, lobby, nil))
+}
+
+func (p *Peer) joinedPacket(rid string, lobby stores.Lobby, sharedState map[string]stores.StateEntry) JoinedPacket {
+	packet := JoinedPacket{
+		RequestID:   rid,
+		Type:        "joined",
+		LobbyInfo:   lobby,
+		SharedState: sharedState,
+	}
+	if !p.Has(CapabilityLobbyInfo) {
+		packet.LobbyCode = lobby.Code
+	}
+	return packet
 }
 
 func (p *Peer) HandleJoinPacket(ctx context.Context, packet JoinPacket) error {
 	logger := logging.GetLogger(ctx)
 	if p.ID == "" {
-		return fmt.Errorf("peer not connected")
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
 	}
 	if p.Lobby != "" {
-		return fmt.Errorf("already in a lobby %s:%s as %s", p.Game, p.Lobby, p.ID)
+		util.ReplyError(ctx, p.conn, ErrAlreadyInLobby)
+		return nil
 	}
 	if packet.Lobby == "" {
-		return fmt.Errorf("no lobby code supplied")
+		util.ReplyError(ctx, p.conn, util.ErrorWithCode(fmt.Errorf("no lobby code supplied"), "invalid-lobby-code"))
+		return nil
 	}
 	if len(packet.Lobby) > 20 {
-		return fmt.Errorf("lobby code too long")
+		util.ReplyError(ctx, p.conn, util.ErrorWithCode(fmt.Errorf("lobby code too long"), "invalid-lobby-code"))
+		return nil
+	}
+	if packet.PeerCustomData != nil {
+		if err := p.config.CheckPeerCustomData(packet.PeerCustomData); err != nil {
+			util.ReplyError(ctx, p.conn, err)
+			return nil
+		}
+		if err := p.moderateCustomData(p.config, "peerCustomData", packet.PeerCustomData); err != nil {
+			util.ReplyError(ctx, p.conn, err)
+			return nil
+		}
 	}
 
 	existingPeers, err := p.store.JoinLobby(ctx, p.Game, packet.Lobby, p.ID, packet.Password)
@@ -480,13 +964,28 @@ func (p *Peer) HandleJoinPacket(ctx context.Context, packet JoinPacket) error {
 		case stores.ErrLobbyIsFull:
 			util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "lobby-is-full"))
 			return nil
+		case stores.ErrAlreadyInLobby:
+			util.ReplyError(ctx, p.conn, ErrAlreadyInLobby)
+			return nil
 		}
 
 		return err
 	}
 
 	p.Lobby = packet.Lobby
-	p.store.Subscribe(ctx, p.ForwardMessage, p.Game, p.Lobby, p.ID)
+
+	// Stored after joining so a failed join doesn't change it, but before the other
+	// peers are asked to connect so they get it with the connect packet.
+	if packet.PeerCustomData != nil {
+		if err := p.store.UpdatePeerCustomData(ctx, p.ID, packet.PeerCustomData); err != nil {
+			return fmt.Errorf("unable to update peer custom data: %w", err)
+		}
+	}
+
+	p.subscribeLobby(ctx)
+	if err := p.enableEventLog(ctx); err != nil {
+		return err
+	}
 
 	// Lobby might be empty when joining, then you need to become the leader.
 	_, err = p.doLeaderElectionAndPublish(ctx)
@@ -498,13 +997,12 @@ func (p *Peer) HandleJoinPacket(ctx context.Context, packet JoinPacket) error {
 	if err != nil {
 		return err
 	}
+	sharedState, err := p.store.GetLobbyState(ctx, p.Game, p.Lobby)
+	if err != nil {
+		return err
+	}
 
-	err = p.Send(ctx, JoinedPacket{
-		RequestID: packet.RequestID,
-		Type:      "joined",
-		LobbyCode: p.Lobby, // backwards compatibility
-		LobbyInfo: lobby,
-	})
+	err = p.Send(ctx, p.joinedPacket(packet.RequestID, lobby, sharedState))
 	if err != nil {
 		return err
 	}
@@ -514,7 +1012,7 @@ func (p *Peer) HandleJoinPacket(ctx context.Context, packet JoinPacket) error {
 			continue
 		}
 
-		err := p.RequestConnection(ctx, otherID)
+		err := p.RequestConnection(ctx, otherID, lobby.PeerInfo)
 		if err != nil {
 			return err
 		}
@@ -533,19 +1031,31 @@ func (p *Peer) HandleJoinPacket(ctx context.Context, packet JoinPacket) error {
 func (p *Peer) HandleUpdatePacket(ctx context.Context, packet LobbyUpdatePacket) error {
 	logger := logging.GetLogger(ctx)
 	if p.ID == "" {
-		return fmt.Errorf("peer not connected")
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
 	}
 	if p.Lobby == "" {
-		return fmt.Errorf("not in a lobby")
+		util.ReplyError(ctx, p.conn, ErrNotInLobby)
+		return nil
 	}
 	if packet.CanUpdateBy != nil {
 		if *packet.CanUpdateBy != stores.CanUpdateByCreator &&
 			*packet.CanUpdateBy != stores.CanUpdateByLeader &&
 			*packet.CanUpdateBy != stores.CanUpdateByAnyone &&
 			*packet.CanUpdateBy != stores.CanUpdateByNone {
-			return fmt.Errorf("invalid canUpdateBy value")
+			util.ReplyError(ctx, p.conn, ErrInvalidCanUpdateBy)
+			return nil
 		}
 	}
+	if packet.State != nil && !stores.IsValidLobbyState(*packet.State) {
+		util.ReplyError(ctx, p.conn, util.ErrorWithCode(fmt.Errorf("invalid state value"), "invalid-lobby-state"))
+		return nil
+	}
+
+	if err := p.checkUpdatePacket(packet); err != nil {
+		util.ReplyError(ctx, p.conn, err)
+		return nil
+	}
 
 	err := p.store.UpdateLobby(ctx, p.Game, p.Lobby, p.ID, stores.LobbyOptions{
 		Public:      packet.Public,
@@ -553,10 +1063,39 @@ func (p *Peer) HandleUpdatePacket(ctx context.Context, packet LobbyUpdatePacket)
 		CanUpdateBy: packet.CanUpdateBy,
 		Password:    packet.Password,
 		MaxPlayers:  packet.MaxPlayers,
+		State:       packet.State,
+
+		ReconnectPolicy: packet.ReconnectPolicy,
+
+		CustomDataPatch: packet.CustomDataPatch,
+		SetCustomData:   packet.SetCustomData,
+		UnsetCustomData: packet.UnsetCustomData,
+
+		ExpectedVersion:   packet.ExpectedVersion,
+		ExpectedUpdatedAt: packet.ExpectedUpdatedAt,
+
+		ValidateCustomData: p.config.ValidateCustomData,
 	})
 	if err != nil {
 		logger.Warn("failed to update lobby", zap.Error(err), zap.Any("customData", packet.CustomData))
-		util.ReplyError(ctx, p.conn, fmt.Errorf("unable to update lobby: %v", err))
+		if util.HasErrorCode(err) {
+			util.ReplyError(ctx, p.conn, err)
+			return nil
+		}
+		err = fmt.Errorf("unable to update lobby: %w", err)
+		switch {
+		case errors.Is(err, stores.ErrNotFound):
+			err = util.ErrorWithCode(err, "lobby-not-found")
+		case errors.Is(err, stores.ErrNotAllowed):
+			err = util.ErrorWithCode(err, "update-not-allowed")
+		case errors.Is(err, stores.ErrInvalidStateTransition):
+			err = util.ErrorWithCode(err, "invalid-state-transition")
+		case errors.Is(err, stores.ErrVersionConflict):
+			err = util.ErrorWithCode(err, "version-conflict")
+		default:
+			return err
+		}
+		util.ReplyError(ctx, p.conn, err)
 		return nil
 	}
 
@@ -573,6 +1112,7 @@ func (p *Peer) HandleUpdatePacket(ctx context.Context, packet LobbyUpdatePacket)
 		zap.Bool("public", lobbyInfo.Public),
 		zap.String("canUpdateBy", lobbyInfo.CanUpdateBy),
 		zap.Int("maxPlayers", lobbyInfo.MaxPlayers),
+		zap.String("state", lobbyInfo.State),
 	)
 	go metrics.Record(ctx, "lobby", "updated", p.Game, p.ID, p.Lobby)
 
@@ -587,7 +1127,272 @@ func (p *Peer) HandleUpdatePacket(ctx context.Context, packet LobbyUpdatePacket)
 	if err != nil {
 		return err
 	}
-	return p.store.Publish(ctx, p.Game+p.Lobby, data)
+	if err := p.publishToLobby(ctx, data); err != nil {
+		return err
+	}
+
+	// Changing the state resets the ready flags.
+	if packet.State != nil {
+		data, err := json.Marshal(ReadyUpdatedPacket{
+			Type:       "readyUpdated",
+			ReadyPeers: []string{},
+		})
+		if err != nil {
+			return err
+		}
+		return p.publishToLobby(ctx, data)
+	}
+	return nil
+}
+
+func (p *Peer) HandlePeerUpdatePacket(ctx context.Context, packet PeerUpdatePacket) error {
+	logger := logging.GetLogger(ctx)
+	if p.ID == "" {
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
+	}
+
+	if err := p.config.CheckPeerCustomData(packet.CustomData); err != nil {
+		util.ReplyError(ctx, p.conn, err)
+		return nil
+	}
+	if err := p.moderateCustomData(p.config, "customData", packet.CustomData); err != nil {
+		util.ReplyError(ctx, p.conn, err)
+		return nil
+	}
+	if err := p.store.UpdatePeerCustomData(ctx, p.ID, packet.CustomData); err != nil {
+		return fmt.Errorf("unable to update peer custom data: %w", err)
+	}
+
+	info, err := p.store.GetPeerInfo(ctx, p.Game, p.ID)
+	if err != nil {
+		return err
+	}
+
+	logger.Debug("peer updated", zap.String("game", p.Game), zap.String("lobby", p.Lobby), zap.String("peer", p.ID), zap.Any("customData", info.CustomData))
+	go metrics.Record(ctx, "peer", "updated", p.Game, p.ID, p.Lobby)
+
+	packetOut := PeerUpdatedPacket{
+		// Include the request ID for the peer that requested the update.
+		// Other peers will ignore this.
+		RequestID: packet.RequestID,
+
+		Type: "peerUpdated",
+		Peer: info,
+	}
+
+	// Without a lobby there is nobody else to tell.
+	if p.Lobby == "" {
+		return p.Send(ctx, packetOut)
+	}
+
+	data, err := json.Marshal(packetOut)
+	if err != nil {
+		return err
+	}
+	return p.publishToLobby(ctx, data)
+}
+
+func (p *Peer) HandleReadyPacket(ctx context.Context, packet ReadyPacket) error {
+	logger := logging.GetLogger(ctx)
+	if p.ID == "" {
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
+	}
+	if p.Lobby == "" {
+		util.ReplyError(ctx, p.conn, ErrNotInLobby)
+		return nil
+	}
+
+	readyPeers, allReady, err := p.store.SetPeerReady(ctx, p.Game, p.Lobby, p.ID, packet.Ready)
+	if err != nil {
+		if err == stores.ErrNotFound {
+			util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "lobby-not-found"))
+			return nil
+		}
+		return err
+	}
+
+	logger.Debug("peer ready",
+		zap.String("game", p.Game),
+		zap.String("lobby", p.Lobby),
+		zap.String("peer", p.ID),
+		zap.Bool("ready", packet.Ready),
+		zap.Bool("allReady", allReady),
+	)
+
+	data, err := json.Marshal(ReadyUpdatedPacket{
+		// Include the request ID for the peer that changed its ready flag.
+		// Other peers will ignore this.
+		RequestID: packet.RequestID,
+
+		Type:       "readyUpdated",
+		ID:         p.ID,
+		Ready:      packet.Ready,
+		ReadyPeers: readyPeers,
+	})
+	if err != nil {
+		return err
+	}
+	if err := p.publishToLobby(ctx, data); err != nil {
+		return err
+	}
+
+	if allReady && packet.Ready {
+		go metrics.Record(ctx, "lobby", "all-ready", p.Game, p.ID, p.Lobby)
+
+		data, err := json.Marshal(AllReadyPacket{
+			Type:       "allReady",
+			ReadyPeers: readyPeers,
+		})
+		if err != nil {
+			return err
+		}
+		return p.publishToLobby(ctx, data)
+	}
+	return nil
+}
+
+// publishReadyAfterRemoval is called after a peer was removed from a lobby. It publishes readyUpdated
+// when the peer was ready, and allReady when the peer was the last one that wasn't ready.
+func publishReadyAfterRemoval(ctx context.Context, store stores.Store, game, lobbyCode, peerID string, wasReady bool) error {
+	lobby, err := store.GetLobby(ctx, game, lobbyCode)
+	if errors.Is(err, stores.ErrNotFound) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	readyPeers := lobby.Ready
+	if readyPeers == nil {
+		readyPeers = []string{}
+	}
+
+	if wasReady {
+		data, err := json.Marshal(ReadyUpdatedPacket{
+			Type:       "readyUpdated",
+			ID:         peerID,
+			Ready:      false,
+			ReadyPeers: readyPeers,
+		})
+		if err != nil {
+			return err
+		}
+		if _, err := store.PublishLobbyEvent(ctx, game, lobbyCode, data); err != nil {
+			return err
+		}
+		// The other peers were already all ready before.
+		return nil
+	}
+
+	if len(lobby.Peers) == 0 {
+		return nil
+	}
+	for _, id := range lobby.Peers {
+		if !slices.Contains(lobby.Ready, id) {
+			return nil
+		}
+	}
+	go metrics.Record(ctx, "lobby", "all-ready", game, peerID, lobbyCode)
+	data, err := json.Marshal(AllReadyPacket{
+		Type:       "allReady",
+		ReadyPeers: readyPeers,
+	})
+	if err != nil {
+		return err
+	}
+	_, err = store.PublishLobbyEvent(ctx, game, lobbyCode, data)
+	return err
+}
+
+// checkCreatePacket checks the create packet against the limits of the game.
+func (p *Peer) checkCreatePacket(packet CreatePacket, maxPlayers int) error {
+	if err := checkCodeFormat(packet.CodeFormat, packet.CodeLength); err != nil {
+		return err
+	}
+	if packet.Code != "" {
+		if err := p.config.CheckCustomCode(packet.Code); err != nil {
+			return err
+		}
+		if p.config.IsPersistentLobby(packet.Code) {
+			return util.ErrorWithCode(fmt.Errorf("lobby code %q is reserved for a persistent lobby", packet.Code), "lobby-code-reserved")
+		}
+		if p.moderation.Blocked(packet.Code) {
+			return util.ErrorWithCode(fmt.Errorf("lobby code %q is not allowed", packet.Code), "lobby-code-not-allowed")
+		}
+	}
+	if err := p.config.CheckCodeFormat(packet.CodeFormat); err != nil {
+		return err
+	}
+	if err := p.config.CheckMaxPlayers(maxPlayers); err != nil {
+		return err
+	}
+	if err := p.config.CheckPublic(packet.Public); err != nil {
+		return err
+	}
+	if err := p.moderateCustomData(p.config, "customData", packet.CustomData); err != nil {
+		return err
+	}
+	if packet.TTL < 0 {
+		return util.ErrorWithCode(fmt.Errorf("ttl must not be negative"), "invalid-ttl")
+	}
+	if packet.IdleTimeout < 0 {
+		return util.ErrorWithCode(fmt.Errorf("idleTimeout must not be negative"), "invalid-idle-timeout")
+	}
+	if packet.ReconnectPolicy != "" {
+		if err := checkReconnectPolicy(packet.ReconnectPolicy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moderateCustomData returns an error when a string in data is blocked by the moderation filter.
+func (p *Peer) moderateCustomData(config gameconfig.Config, field string, data map[string]any) error {
+	if !config.ModerateCustomData || data == nil {
+		return nil
+	}
+	if path, blocked := p.moderation.BlockedPath(data); blocked {
+		return util.ErrorWithCode(fmt.Errorf("%s.%s contains blocked content", field, path), "content-not-allowed")
+	}
+	return nil
+}
+
+// checkUpdatePacket checks the update packet against the limits of the game.
+func (p *Peer) checkUpdatePacket(packet LobbyUpdatePacket) error {
+	if packet.MaxPlayers != nil {
+		if err := p.config.CheckMaxPlayers(*packet.MaxPlayers); err != nil {
+			return err
+		}
+	}
+	if packet.Public != nil {
+		if err := p.config.CheckPublic(*packet.Public); err != nil {
+			return err
+		}
+	}
+	if packet.CustomData != nil {
+		if err := p.moderateCustomData(p.config, "customData", *packet.CustomData); err != nil {
+			return err
+		}
+	}
+	if packet.ReconnectPolicy != nil {
+		if err := checkReconnectPolicy(*packet.ReconnectPolicy); err != nil {
+			return err
+		}
+	}
+	if err := p.moderateCustomData(p.config, "customDataPatch", packet.CustomDataPatch); err != nil {
+		return err
+	}
+	if err := p.moderateCustomData(p.config, "setCustomData", packet.SetCustomData); err != nil {
+		return err
+	}
+	return nil
+}
+
+func checkReconnectPolicy(policy string) error {
+	if !stores.IsValidReconnectPolicy(policy) {
+		return util.ErrorWithCode(fmt.Errorf("invalid reconnectPolicy %q", policy), "invalid-reconnect-policy")
+	}
+	return nil
 }
 
 // doLeaderElectionAndPublish will do a leader election and publish the result if a new leader was elected.
@@ -608,7 +1413,7 @@ func (p *Peer) doLeaderElectionAndPublish(ctx context.Context) (bool, error) {
 		if err != nil {
 			return false, err
 		}
-		err = p.store.Publish(ctx, p.Game+p.Lobby, data)
+		err = p.publishToLobby(ctx, data)
 		if err != nil {
 			return false, err
 		}
@@ -616,3 +1421,179 @@ func (p *Peer) doLeaderElectionAndPublish(ctx context.Context) (bool, error) {
 
 	return result != nil, nil
 }
+
+func (p *Peer) HandleSetStatePacket(ctx context.Context, packet SetStatePacket) error {
+	logger := logging.GetLogger(ctx)
+	if p.ID == "" {
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
+	}
+	if p.Lobby == "" {
+		util.ReplyError(ctx, p.conn, ErrNotInLobby)
+		return nil
+	}
+	if packet.Key == "" || len(packet.Key) > 64 {
+		util.ReplyError(ctx, p.conn, util.ErrorWithCode(fmt.Errorf("state keys must be 1 to 64 characters"), "invalid-state-key"))
+		return nil
+	}
+	if packet.Access != "" && !stores.IsValidStateAccess(packet.Access) {
+		util.ReplyError(ctx, p.conn, util.ErrorWithCode(fmt.Errorf("invalid access value"), "invalid-state-access"))
+		return nil
+	}
+	if p.config.SharedStateMaxBytes > 0 && len(packet.Value) > p.config.SharedStateMaxBytes {
+		err := fmt.Errorf("state value is %d bytes, it can't be more than %d bytes", len(packet.Value), p.config.SharedStateMaxBytes)
+		util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "state-value-too-large"))
+		return nil
+	}
+
+	update := stores.StateUpdate{
+		Value:           packet.Value,
+		Access:          packet.Access,
+		ExpectedVersion: packet.ExpectedVersion,
+		MaxKeys:         p.config.SharedStateMaxKeys,
+	}
+	entry, err := p.store.SetLobbyState(ctx, p.Game, p.Lobby, p.ID, packet.Key, update)
+	if err != nil {
+		switch {
+		case errors.Is(err, stores.ErrNotFound):
+			err = util.ErrorWithCode(err, "lobby-not-found")
+		case errors.Is(err, stores.ErrStateNotAllowed):
+			err = util.ErrorWithCode(err, "state-not-allowed")
+		case errors.Is(err, stores.ErrTooManyStateKeys):
+			err = util.ErrorWithCode(err, "too-many-state-keys")
+		case errors.Is(err, stores.ErrVersionConflict):
+			err = util.ErrorWithCode(err, "version-conflict")
+		default:
+			return err
+		}
+		util.ReplyError(ctx, p.conn, err)
+		return nil
+	}
+
+	if entry.Version == 0 {
+		// Removed a key that didn't exist, nothing changed.
+		return nil
+	}
+
+	logger.Debug("lobby state updated",
+		zap.String("game", p.Game),
+		zap.String("lobby", p.Lobby),
+		zap.String("peer", p.ID),
+		zap.String("key", packet.Key),
+		zap.Int("version", entry.Version),
+	)
+
+	data, err := json.Marshal(StateUpdatedPacket{
+		// Include the request ID for the peer that changed the state.
+		// Other peers will ignore this.
+		RequestID: packet.RequestID,
+
+		Type:       "stateUpdated",
+		Key:        packet.Key,
+		UpdatedBy:  p.ID,
+		Deleted:    update.Deleted(),
+		StateEntry: entry,
+	})
+	if err != nil {
+		return err
+	}
+	return p.publishToLobby(ctx, data)
+}
+
+func (p *Peer) HandleGetStatePacket(ctx context.Context, packet GetStatePacket) error {
+	if p.ID == "" {
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
+	}
+	if p.Lobby == "" {
+		util.ReplyError(ctx, p.conn, ErrNotInLobby)
+		return nil
+	}
+
+	state, err := p.store.GetLobbyState(ctx, p.Game, p.Lobby)
+	if err != nil {
+		return err
+	}
+	return p.Send(ctx, StatePacket{
+		RequestID: packet.RequestID,
+		Type:      "state",
+		State:     state,
+	})
+}
+
+// publishToLobby publishes data to all peers in the lobby and adds it to the event log of the lobby.
+func (p *Peer) publishToLobby(ctx context.Context, data []byte) error {
+	_, err := p.store.PublishLobbyEvent(ctx, p.Game, p.Lobby, data)
+	return err
+}
+
+func (p *Peer) HandleReplayPacket(ctx context.Context, packet ReplayPacket) error {
+	if p.ID == "" {
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
+	}
+	if p.Lobby == "" {
+		util.ReplyError(ctx, p.conn, ErrNotInLobby)
+		return nil
+	}
+	return p.replay(ctx, packet.RequestID, packet.Since)
+}
+
+// replay sends the lobby events after since to the peer, followed by a replayed packet.
+// Events published while replaying are also forwarded as usual, clients should ignore
+// events with a seq they already handled.
+func (p *Peer) replay(ctx context.Context, requestID string, since int64) error {
+	events, seq, complete, err := p.store.GetLobbyEvents(ctx, p.Game, p.Lobby, since)
+	if err != nil {
+		if err == stores.ErrNotFound {
+			util.ReplyError(ctx, p.conn, util.ErrorWithCode(err, "lobby-not-found"))
+			return nil
+		}
+		return err
+	}
+
+	for _, event := range events {
+		if err := p.Send(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	replayed := ReplayedPacket{
+		RequestID: requestID,
+		Type:      "replayed",
+		Lobby:     p.Lobby,
+		Seq:       seq,
+		Complete:  complete,
+	}
+	if !complete {
+		lobby, err := p.store.GetLobby(ctx, p.Game, p.Lobby)
+		if err != nil {
+			return err
+		}
+		replayed.LobbyInfo = &lobby
+	}
+	return p.Send(ctx, replayed)
+}
+
+func (p *Peer) HandleAckPacket(ctx context.Context, packet AckPacket) error {
+	if p.ID == "" {
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
+	}
+	return p.store.AckPeerMessages(ctx, p.ID, packet.MsgSeq)
+}
+
+// resendUnackedMessages sends the messages that were sent to the peer but weren't acked,
+// these might have been lost while the peer was reconnecting.
+func (p *Peer) resendUnackedMessages(ctx context.Context) error {
+	messages, err := p.store.GetPeerMessages(ctx, p.ID)
+	if err != nil {
+		return err
+	}
+	for _, message := range messages {
+		if err := p.Send(ctx, message); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package signaling
+
+import "slices"
+
+// ProtocolVersion is the version of the signaling protocol, sent to clients in welcome.
+// It's incremented when packets change in a way clients need to know about.
+const ProtocolVersion = 2
+
+// Only features that change what the server sends have a capability, features that
+// only add packets clients can send are available to every client.
+const (
+	// CapabilityAcks is for clients that ack the messages sent to them, see AckPacket.
+	CapabilityAcks = "acks"
+	// CapabilityLobbyInfo is for clients that read the lobby code from lobbyInfo,
+	// they don't get the old lobby field in joined packets.
+	CapabilityLobbyInfo = "lobbyInfo"
+	// CapabilityReplay is for clients that replay lobby events, only their lobbies keep an event log.
+	CapabilityReplay = "replay"
+)
+
+// Capabilities are the protocol features this server supports.
+var Capabilities = []string{
+	CapabilityAcks,
+	CapabilityLobbyInfo,
+	CapabilityReplay,
+}
+
+// negotiateCapabilities returns the capabilities both the client and this server support.
+func negotiateCapabilities(client []string) map[string]bool {
+	negotiated := make(map[string]bool)
+	for _, capability := range client {
+		if slices.Contains(Capabilities, capability) {
+			negotiated[capability] = true
+		}
+	}
+	return negotiated
+}
+
+// Has returns whether the client of this peer negotiated capability in its hello.
+func (p *Peer) Has(capability string) bool {
+	return p.capabilities[capability]
+}
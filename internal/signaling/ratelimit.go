@@ -0,0 +1,151 @@
+package signaling
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/poki/netlib/internal/util"
+)
+
+const rateLimitPruneInterval = 5 * time.Minute
+
+// RateLimits configures how many packets of each type a single peer and a single
+// remote IP are allowed to send. Packet types without
+// a limit are not rate limited.
+type RateLimits struct {
+	Peer map[string]util.RateLimit
+	IP   map[string]util.RateLimit
+}
+
+// DefaultRateLimits returns the limits used when nothing is configured. The IP
+// limits are higher as multiple players can share an IP address.
+func DefaultRateLimits() RateLimits {
+	return RateLimits{
+		Peer: map[string]util.RateLimit{
+			"create":    {Rate: 1, Burst: 5},
+			"list":      {Rate: 2, Burst: 10},
+			"join":      {Rate: 1, Burst: 5},
+			"event":     {Rate: 10, Burst: 50},
+			"candidate": {Rate: 50, Burst: 200},
+		},
+		IP: map[string]util.RateLimit{
+			"create":    {Rate: 10, Burst: 50},
+			"list":      {Rate: 20, Burst: 100},
+			"join":      {Rate: 10, Burst: 50},
+			"event":     {Rate: 100, Burst: 500},
+			"candidate": {Rate: 500, Burst: 2000},
+		},
+	}
+}
+
+// RateLimitsFromEnv returns the default rate limits overridden by RATE_LIMIT_PEER and RATE_LIMIT_IP.
+// Both are a comma separated list of type=rate:burst, where rate is in packets per second.
+// For example: RATE_LIMIT_PEER="create=0.5:2,list=2:10". A burst of 0 disables the limit for that type,
+// limits with a burst need a positive rate, otherwise the bucket would never refill.
+func RateLimitsFromEnv() (RateLimits, error) {
+	limits := DefaultRateLimits()
+	if err := parseRateLimits(os.Getenv("RATE_LIMIT_PEER"), limits.Peer); err != nil {
+		return limits, fmt.Errorf("invalid RATE_LIMIT_PEER: %w", err)
+	}
+	if err := parseRateLimits(os.Getenv("RATE_LIMIT_IP"), limits.IP); err != nil {
+		return limits, fmt.Errorf("invalid RATE_LIMIT_IP: %w", err)
+	}
+	return limits, nil
+}
+
+func parseRateLimits(in string, into map[string]util.RateLimit) error {
+	for _, entry := range strings.Split(in, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		typ, spec, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("missing = in %q", entry)
+		}
+		rateStr, burstStr, ok := strings.Cut(spec, ":")
+		if !ok {
+			return fmt.Errorf("missing : in %q", entry)
+		}
+		rate, err := strconv.ParseFloat(rateStr, 64)
+		if err != nil || rate < 0 {
+			return fmt.Errorf("invalid rate in %q", entry)
+		}
+		burst, err := strconv.Atoi(burstStr)
+		if err != nil || burst < 0 {
+			return fmt.Errorf("invalid burst in %q", entry)
+		}
+		if burst > 0 && rate == 0 {
+			return fmt.Errorf("rate must be positive when burst is set in %q", entry)
+		}
+		into[strings.TrimSpace(typ)] = util.RateLimit{Rate: rate, Burst: burst}
+	}
+	return nil
+}
+
+// packetRateLimiter applies RateLimits to incoming packets. The IP buckets
+// are shared between all connections, the peer buckets are keyed by peer, or by
+// connection before the peer said hello.
+type packetRateLimiter struct {
+	limits  RateLimits
+	peers   *util.RateLimiter
+	ips     *util.RateLimiter
+	maxIdle time.Duration
+}
+
+func newPacketRateLimiter(limits RateLimits) *packetRateLimiter {
+	// A bucket that hasn't been used for the time it takes to refill completely
+	// is identical to a new bucket, so those can safely be pruned.
+	maxIdle := time.Minute
+	for _, m := range []map[string]util.RateLimit{limits.Peer, limits.IP} {
+		for _, limit := range m {
+			if limit.Rate > 0 {
+				maxIdle = max(maxIdle, time.Duration(float64(limit.Burst)/limit.Rate*float64(time.Second)))
+			}
+		}
+	}
+
+	return &packetRateLimiter{
+		limits:  limits,
+		peers:   util.NewRateLimiter(),
+		ips:     util.NewRateLimiter(),
+		maxIdle: maxIdle,
+	}
+}
+
+// Allow reports whether a packet of typ from the given peer and ip is allowed.
+// A packet rejected by the IP limit doesn't use up a token of the peer.
+func (l *packetRateLimiter) Allow(peerKey, ip, typ string, now time.Time) bool {
+	peerLimit, hasPeerLimit := l.limits.Peer[typ]
+	if hasPeerLimit {
+		if !l.peers.Allow(peerKey+":"+typ, peerLimit, now) {
+			return false
+		}
+	}
+	if limit, ok := l.limits.IP[typ]; ok && ip != "" {
+		if !l.ips.Allow(ip+":"+typ, limit, now) {
+			if hasPeerLimit {
+				l.peers.Refund(peerKey+":"+typ, peerLimit)
+			}
+			return false
+		}
+	}
+	return true
+}
+
+func (l *packetRateLimiter) Prune(now time.Time) {
+	l.peers.Prune(now.Add(-l.maxIdle))
+	l.ips.Prune(now.Add(-l.maxIdle))
+}
+
+// remoteIP returns the IP address of the client without port.
+func remoteIP(addr string) string {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		return host
+	}
+	return addr
+}
@@ -0,0 +1,228 @@
+package schema
+
+import (
+	"bytes"
+	"fmt"
+	"go/format"
+	"strings"
+	"unicode"
+)
+
+// goNames are the Go field names of properties that aren't just capitalized.
+var goNames = map[string]string{
+	"id":  "ID",
+	"rid": "RequestID",
+	"ttl": "TTL",
+}
+
+// GoName returns the name of the Go field for a property.
+func GoName(property string) string {
+	if name, ok := goNames[property]; ok {
+		return name
+	}
+	r := []rune(property)
+	r[0] = unicode.ToUpper(r[0])
+	return string(r)
+}
+
+// GoType returns the Go type of a property.
+func (p *Property) GoType() string {
+	var typ string
+	switch p.Type {
+	case "string":
+		typ = "string"
+		if p.Format == "date-time" {
+			typ = "time.Time"
+		}
+	case "integer":
+		typ = "int"
+		if p.Format == "int64" {
+			typ = "int64"
+		}
+	case "number":
+		typ = "float64"
+	case "boolean":
+		typ = "bool"
+	case "object":
+		typ = "map[string]any"
+	case "array":
+		return "[]" + p.Items.GoType()
+	default:
+		return "json.RawMessage"
+	}
+	if p.Nullable {
+		return "*" + typ
+	}
+	return typ
+}
+
+// TSType returns the TypeScript type of a property.
+func (p *Property) TSType() string {
+	if p.TSTypeOverride != "" {
+		return p.TSTypeOverride
+	}
+	var typ string
+	switch p.Type {
+	case "string":
+		typ = "string"
+		if p.Const != "" {
+			typ = "'" + p.Const + "'"
+		}
+	case "integer", "number":
+		typ = "number"
+	case "boolean":
+		typ = "boolean"
+	case "object":
+		typ = "{ [key: string]: any }"
+	case "array":
+		items := p.Items.TSType()
+		if strings.Contains(items, " ") {
+			items = "Array<" + items + ">"
+		} else {
+			items += "[]"
+		}
+		typ = items
+	default:
+		return "any"
+	}
+	if p.Nullable {
+		return typ + " | null"
+	}
+	return typ
+}
+
+// GenerateGo returns the Go source of the packet types in package pkg.
+func (s *Schema) GenerateGo(pkg string) ([]byte, error) {
+	var body bytes.Buffer
+	imports := map[string]bool{}
+	for _, packet := range s.Packets {
+		body.WriteString("\n")
+		writeComment(&body, "", packet.Description)
+		fmt.Fprintf(&body, "type %s struct {\n", packet.Name)
+
+		// rid and type go first, then groups of fields without a description and
+		// fields with a description on their own.
+		var header, rest []*Property
+		for _, prop := range packet.Properties {
+			if prop.Name == "rid" || prop.Name == "type" {
+				header = append(header, prop)
+			} else {
+				rest = append(rest, prop)
+			}
+		}
+		for _, prop := range header {
+			writeGoField(&body, prop, imports)
+		}
+		grouped := false
+		for _, prop := range rest {
+			if prop.Description != "" || !grouped {
+				body.WriteString("\n")
+			}
+			writeComment(&body, "\t", prop.Description)
+			writeGoField(&body, prop, imports)
+			grouped = prop.Description == ""
+		}
+		body.WriteString("}\n")
+	}
+
+	var out bytes.Buffer
+	out.WriteString("// Code generated by packetgen from schema/packets.json. DO NOT EDIT.\n\n")
+	fmt.Fprintf(&out, "package %s\n", pkg)
+	if len(imports) > 0 {
+		out.WriteString("\nimport (\n")
+		for _, imp := range []string{"encoding/json", "time"} {
+			if imports[imp] {
+				fmt.Fprintf(&out, "\t%q\n", imp)
+			}
+		}
+		out.WriteString(")\n")
+	}
+	out.Write(body.Bytes())
+	return format.Source(out.Bytes())
+}
+
+func writeGoField(w *bytes.Buffer, prop *Property, imports map[string]bool) {
+	typ := prop.GoType()
+	if strings.Contains(typ, "json.") {
+		imports["encoding/json"] = true
+	}
+	if strings.Contains(typ, "time.") {
+		imports["time"] = true
+	}
+	// Optional properties are left out when they're empty, so the decoded packet still matches the schema.
+	tag := prop.Name
+	if !prop.Required {
+		tag += ",omitempty"
+	}
+	fmt.Fprintf(w, "\t%s %s `json:%q`\n", GoName(prop.Name), typ, tag)
+}
+
+// GenerateTS returns the TypeScript source of the packet types.
+func (s *Schema) GenerateTS() []byte {
+	var out bytes.Buffer
+	out.WriteString("// Code generated by packetgen from internal/signaling/schema/packets.json. DO NOT EDIT.\n")
+	out.WriteString("\nexport type ClientPacketTypes =\n")
+	for _, packet := range s.Packets {
+		fmt.Fprintf(&out, "| %s\n", packet.Name)
+	}
+	for _, packet := range s.Packets {
+		out.WriteString("\n")
+		writeTSComment(&out, "", packet.Description)
+		fmt.Fprintf(&out, "export interface %s {\n", packet.Name)
+		typ := packet.Property("type")
+		fmt.Fprintf(&out, "  type: %s\n", typ.TSType())
+		// Every packet can have a rid, the reply to it gets the same rid.
+		out.WriteString("  rid?: string\n")
+		for _, prop := range packet.Properties {
+			if prop.Name == "rid" || prop.Name == "type" {
+				continue
+			}
+			writeTSComment(&out, "  ", prop.Description)
+			optional := "?"
+			if prop.Required {
+				optional = ""
+			}
+			fmt.Fprintf(&out, "  %s%s: %s\n", prop.Name, optional, prop.TSType())
+		}
+		out.WriteString("}\n")
+	}
+	return out.Bytes()
+}
+
+func writeComment(w *bytes.Buffer, indent, text string) {
+	for _, line := range wrap(text, 100) {
+		fmt.Fprintf(w, "%s// %s\n", indent, line)
+	}
+}
+
+func writeTSComment(w *bytes.Buffer, indent, text string) {
+	lines := wrap(text, 100)
+	if len(lines) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "%s/**\n", indent)
+	for _, line := range lines {
+		fmt.Fprintf(w, "%s * %s\n", indent, line)
+	}
+	fmt.Fprintf(w, "%s */\n", indent)
+}
+
+// wrap splits text into lines of at most width characters, unless a word is longer.
+func wrap(text string, width int) []string {
+	var lines []string
+	var line string
+	for _, word := range strings.Fields(text) {
+		if line != "" && len(line)+1+len(word) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += " "
+		}
+		line += word
+	}
+	if line != "" {
+		lines = append(lines, line)
+	}
+	return lines
+}
@@ -0,0 +1,317 @@
+// Package schema contains the schema of the packets clients send to the signaling server.
+//
+// packets.json is the source of the packet types in internal/signaling/packets_gen.go and
+// lib/packets.ts, which are generated with cmd/packetgen. The server uses it to validate
+// packets after decoding them, see Validate.
+package schema
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+
+	"github.com/xeipuuv/gojsonschema"
+)
+
+//go:embed packets.json
+var packetsJSON []byte
+
+// Schema is a parsed packets.json.
+type Schema struct {
+	Title       string
+	Description string
+
+	// Packets are in the order of packets.json.
+	Packets []*Packet
+}
+
+// Packet is the schema of a packet type.
+type Packet struct {
+	// Name is the name of the generated types, like HelloPacket.
+	Name        string
+	Description string
+
+	// Type is the value of the type property, like hello.
+	Type string
+
+	// Properties are in the order of packets.json.
+	Properties []*Property
+}
+
+// Property is the schema of a packet property, or of the items of an array.
+type Property struct {
+	Name        string
+	Description string
+
+	// Type is string, integer, number, boolean, object or array, empty for any JSON value.
+	Type     string
+	Nullable bool
+	Required bool
+
+	// Format is int64 for integers or date-time for strings.
+	Format string
+	Const  string
+	Items  *Property
+
+	// TSType overrides the generated TypeScript type.
+	TSTypeOverride string
+}
+
+type rawProperty struct {
+	Type        json.RawMessage `json:"type"`
+	Description string          `json:"description"`
+	Format      string          `json:"format"`
+	Const       *string         `json:"const"`
+	Items       *rawProperty    `json:"items"`
+	TSType      string          `json:"x-ts-type"`
+}
+
+type rawPacket struct {
+	Description string          `json:"description"`
+	Properties  json.RawMessage `json:"properties"`
+	Required    []string        `json:"required"`
+}
+
+var packets = func() *Schema {
+	s, err := Parse(packetsJSON)
+	if err != nil {
+		panic(fmt.Errorf("invalid packets.json: %w", err))
+	}
+	return s
+}()
+
+// Packets returns the schema embedded from packets.json.
+func Packets() *Schema {
+	return packets
+}
+
+// Parse parses a schema in the format of packets.json.
+func Parse(data []byte) (*Schema, error) {
+	var raw struct {
+		Title       string          `json:"title"`
+		Description string          `json:"description"`
+		Defs        json.RawMessage `json:"$defs"`
+	}
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, err
+	}
+	s := &Schema{
+		Title:       raw.Title,
+		Description: raw.Description,
+	}
+
+	names, defs, err := orderedObject(raw.Defs)
+	if err != nil {
+		return nil, fmt.Errorf("$defs: %w", err)
+	}
+	for _, name := range names {
+		var def rawPacket
+		if err := json.Unmarshal(defs[name], &def); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		packet := &Packet{
+			Name:        name,
+			Description: def.Description,
+		}
+		propNames, props, err := orderedObject(def.Properties)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		for _, propName := range propNames {
+			var rp rawProperty
+			if err := json.Unmarshal(props[propName], &rp); err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", name, propName, err)
+			}
+			prop, err := parseProperty(propName, rp)
+			if err != nil {
+				return nil, fmt.Errorf("%s.%s: %w", name, propName, err)
+			}
+			prop.Required = slices.Contains(def.Required, propName)
+			if propName == "type" {
+				packet.Type = prop.Const
+			}
+			packet.Properties = append(packet.Properties, prop)
+		}
+		if packet.Type == "" {
+			return nil, fmt.Errorf("%s: missing type const", name)
+		}
+		for _, required := range def.Required {
+			if packet.Property(required) == nil {
+				return nil, fmt.Errorf("%s: required property %s doesn't exist", name, required)
+			}
+		}
+		s.Packets = append(s.Packets, packet)
+	}
+	return s, nil
+}
+
+func parseProperty(name string, raw rawProperty) (*Property, error) {
+	prop := &Property{
+		Name:           name,
+		Description:    raw.Description,
+		Format:         raw.Format,
+		TSTypeOverride: raw.TSType,
+	}
+	if raw.Const != nil {
+		prop.Type = "string"
+		prop.Const = *raw.Const
+	}
+	if len(raw.Type) > 0 {
+		var types []string
+		if raw.Type[0] == '[' {
+			if err := json.Unmarshal(raw.Type, &types); err != nil {
+				return nil, err
+			}
+		} else {
+			var typ string
+			if err := json.Unmarshal(raw.Type, &typ); err != nil {
+				return nil, err
+			}
+			types = []string{typ}
+		}
+		for _, typ := range types {
+			switch typ {
+			case "null":
+				prop.Nullable = true
+			case "string", "integer", "number", "boolean", "object", "array":
+				if prop.Type != "" {
+					return nil, fmt.Errorf("multiple types other than null aren't supported")
+				}
+				prop.Type = typ
+			default:
+				return nil, fmt.Errorf("unknown type %q", typ)
+			}
+		}
+	}
+	if prop.Type == "array" {
+		if raw.Items == nil {
+			return nil, fmt.Errorf("missing items")
+		}
+		items, err := parseProperty(name, *raw.Items)
+		if err != nil {
+			return nil, fmt.Errorf("items: %w", err)
+		}
+		prop.Items = items
+	}
+	return prop, nil
+}
+
+// orderedObject returns the keys of a JSON object in the order they appear in, and their values.
+func orderedObject(data []byte) ([]string, map[string]json.RawMessage, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	if tok, err := dec.Token(); err != nil {
+		return nil, nil, err
+	} else if tok != json.Delim('{') {
+		return nil, nil, fmt.Errorf("expected an object")
+	}
+	var keys []string
+	values := make(map[string]json.RawMessage)
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key := tok.(string)
+		var value json.RawMessage
+		if err := dec.Decode(&value); err != nil {
+			return nil, nil, err
+		}
+		keys = append(keys, key)
+		values[key] = value
+	}
+	return keys, values, nil
+}
+
+// Packet returns the schema of the packet with type typ, or nil.
+func (s *Schema) Packet(typ string) *Packet {
+	for _, packet := range s.Packets {
+		if packet.Type == typ {
+			return packet
+		}
+	}
+	return nil
+}
+
+// Property returns the property called name, or nil.
+func (p *Packet) Property(name string) *Property {
+	for _, prop := range p.Properties {
+		if prop.Name == name {
+			return prop
+		}
+	}
+	return nil
+}
+
+// ValidationError is returned by Validate and DecodeError for packets that don't match their schema.
+type ValidationError struct {
+	Packet string `json:"packet"`
+	Field  string `json:"field"`
+	Reason string `json:"reason"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s packet: %s: %s", e.Packet, e.Field, e.Reason)
+}
+
+func (e *ValidationError) ErrorCode() string {
+	return "invalid-packet"
+}
+
+// validators are the schemas of the packet types compiled from packets.json, by packet type.
+var validators = func() map[string]*gojsonschema.Schema {
+	var doc map[string]any
+	if err := json.Unmarshal(packetsJSON, &doc); err != nil {
+		panic(fmt.Errorf("invalid packets.json: %w", err))
+	}
+	validators := make(map[string]*gojsonschema.Schema)
+	for _, packet := range packets.Packets {
+		// The whole document is compiled for every packet, the $ref picks the packet from $defs.
+		root := maps.Clone(doc)
+		root["$ref"] = "#/$defs/" + packet.Name
+		schema, err := gojsonschema.NewSchema(gojsonschema.NewGoLoader(root))
+		if err != nil {
+			panic(fmt.Errorf("invalid packets.json: %s: %w", packet.Name, err))
+		}
+		validators[packet.Type] = schema
+	}
+	return validators
+}()
+
+// Validate checks a decoded packet of type typ, like a *HelloPacket, against the schema from
+// packets.json. Unknown packet types are not validated. Properties that aren't in the schema
+// are ignored.
+func Validate(typ string, packet any) error {
+	validator := validators[typ]
+	if validator == nil {
+		return nil
+	}
+	result, err := validator.Validate(gojsonschema.NewGoLoader(packet))
+	if err != nil {
+		return &ValidationError{Packet: typ, Field: "packet", Reason: err.Error()}
+	}
+	if result.Valid() {
+		return nil
+	}
+	first := result.Errors()[0]
+	field := first.Field()
+	if property, ok := first.Details()["property"].(string); ok && first.Type() == "required" {
+		field = property
+	} else if field == gojsonschema.STRING_ROOT_SCHEMA_PROPERTY {
+		field = "packet"
+	}
+	return &ValidationError{Packet: typ, Field: field, Reason: first.Description()}
+}
+
+// DecodeError returns the error for a packet of type typ that couldn't be decoded into its
+// packet type, like when a property has the wrong type.
+func DecodeError(typ string, err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return &ValidationError{Packet: typ, Field: typeErr.Field, Reason: "Invalid type. Given: " + typeErr.Value}
+	}
+	return &ValidationError{Packet: typ, Field: "packet", Reason: err.Error()}
+}
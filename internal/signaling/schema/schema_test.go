@@ -0,0 +1,114 @@
+package schema_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/poki/netlib/internal/signaling/schema"
+)
+
+func Test_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		typ   string
+		raw   string
+		field string
+	}{
+		{"valid hello", "hello", `{"type":"hello","game":"g","since":42,"capabilities":["acks"]}`, ""},
+		{"null since", "hello", `{"type":"hello","game":"g","since":null}`, ""},
+		{"missing game", "hello", `{"type":"hello"}`, "game"},
+		{"game not a string", "hello", `{"type":"hello","game":1}`, "game"},
+		{"since not an integer", "hello", `{"type":"hello","game":"g","since":1.5}`, "since"},
+		{"capabilities not strings", "hello", `{"type":"hello","game":"g","capabilities":[1]}`, "capabilities.0"},
+		{"unknown properties are ignored", "hello", `{"type":"hello","game":"g","foo":1}`, ""},
+		{"null not allowed", "join", `{"type":"join","lobby":null}`, "lobby"},
+		{"nullable object", "lobbyUpdate", `{"type":"lobbyUpdate","customData":null}`, ""},
+		{"object", "lobbyUpdate", `{"type":"lobbyUpdate","setCustomData":[]}`, "setCustomData"},
+		{"date-time", "lobbyUpdate", `{"type":"lobbyUpdate","expectedUpdatedAt":"2024-01-02T03:04:05Z"}`, ""},
+		{"invalid date-time", "lobbyUpdate", `{"type":"lobbyUpdate","expectedUpdatedAt":"yesterday"}`, "expectedUpdatedAt"},
+		{"any value", "setState", `{"type":"setState","key":"map","value":[1,"a",null]}`, ""},
+		{"missing msgSeq", "ack", `{"type":"ack"}`, "msgSeq"},
+		{"missing recipient", "candidate", `{"type":"candidate","source":"a","candidate":null}`, "recipient"},
+		{"not an object", "ready", `[true]`, "packet"},
+		{"unknown type", "foo", `{"type":"foo","bar":1}`, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var packet any
+			if err := json.Unmarshal([]byte(tt.raw), &packet); err != nil {
+				t.Fatal(err)
+			}
+			err := schema.Validate(tt.typ, packet)
+			if tt.field == "" {
+				if err != nil {
+					t.Fatalf("expected no error, got %v", err)
+				}
+				return
+			}
+			var verr *schema.ValidationError
+			if !errors.As(err, &verr) {
+				t.Fatalf("expected a validation error, got %v", err)
+			}
+			if verr.Field != tt.field {
+				t.Errorf("expected an error for %s, got %v", tt.field, err)
+			}
+			if verr.ErrorCode() != "invalid-packet" {
+				t.Errorf("expected code invalid-packet, got %s", verr.ErrorCode())
+			}
+		})
+	}
+}
+
+func Test_DecodeError(t *testing.T) {
+	var packet struct {
+		Game string `json:"game"`
+	}
+	err := schema.DecodeError("hello", json.Unmarshal([]byte(`{"game":1}`), &packet))
+	var verr *schema.ValidationError
+	if !errors.As(err, &verr) || verr.Field != "game" {
+		t.Errorf("expected a validation error for game, got %v", err)
+	}
+}
+
+func Test_Parse(t *testing.T) {
+	tests := []struct {
+		name   string
+		schema string
+	}{
+		{"missing type", `{"$defs":{"APacket":{"properties":{"a":{"type":"string"}}}}}`},
+		{"unknown type", `{"$defs":{"APacket":{"properties":{"type":{"const":"a"},"a":{"type":"date"}}}}}`},
+		{"missing items", `{"$defs":{"APacket":{"properties":{"type":{"const":"a"},"a":{"type":"array"}}}}}`},
+		{"unknown required", `{"$defs":{"APacket":{"properties":{"type":{"const":"a"}},"required":["b"]}}}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := schema.Parse([]byte(tt.schema)); err == nil {
+				t.Error("expected an error")
+			}
+		})
+	}
+}
+
+// Test_Generated fails when packets.json was changed without running go generate ./internal/signaling.
+func Test_Generated(t *testing.T) {
+	packets := schema.Packets()
+
+	src, err := packets.GenerateGo("signaling")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if current, err := os.ReadFile("../packets_gen.go"); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(current, src) {
+		t.Error("internal/signaling/packets_gen.go is out of date")
+	}
+
+	if current, err := os.ReadFile("../../../lib/packets.ts"); err != nil {
+		t.Fatal(err)
+	} else if !bytes.Equal(current, packets.GenerateTS()) {
+		t.Error("lib/packets.ts is out of date")
+	}
+}
@@ -0,0 +1,76 @@
+package signaling
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/coder/websocket"
+	"github.com/koenbollen/logging"
+	"github.com/poki/netlib/internal/signaling/stores"
+	"github.com/poki/netlib/internal/util"
+	"go.uber.org/zap"
+)
+
+// transferTokenTTL is how long a transfer token can be used to take over a peer.
+const transferTokenTTL = 5 * time.Minute
+
+func (p *Peer) HandleCreateTransferTokenPacket(ctx context.Context, packet CreateTransferTokenPacket) error {
+	if p.ID == "" {
+		util.ReplyError(ctx, p.conn, ErrPeerNotConnected)
+		return nil
+	}
+
+	token := util.GenerateTransferToken(ctx)
+	expiresAt := util.NowUTC(ctx).Add(transferTokenTTL)
+	if err := p.store.CreateTransferToken(ctx, p.ID, token, expiresAt); err != nil {
+		return fmt.Errorf("unable to create transfer token: %w", err)
+	}
+
+	return p.Send(ctx, TransferTokenPacket{
+		RequestID: packet.RequestID,
+		Type:      "transferToken",
+		Token:     token,
+		ExpiresAt: expiresAt,
+	})
+}
+
+// publishSessionTakeover tells the other connections of this peer that this connection took over.
+// reason is the error code they close with.
+func (p *Peer) publishSessionTakeover(ctx context.Context, reason string) error {
+	data, err := json.Marshal(SessionTakeoverPacket{
+		Type:      "sessionTakeover",
+		Conn:      p.connID,
+		Reason:    reason,
+		StartedAt: p.sessionStartedAt,
+	})
+	if err != nil {
+		return err
+	}
+	return p.store.Publish(ctx, stores.SessionTopic(p.Game, p.ID), data)
+}
+
+// handleSessionMessage closes this connection when another connection took over the peer.
+func (p *Peer) handleSessionMessage(ctx context.Context, raw []byte) {
+	logger := logging.GetLogger(ctx)
+
+	var packet SessionTakeoverPacket
+	if err := json.Unmarshal(raw, &packet); err != nil {
+		logger.Warn("invalid session message", zap.Error(err))
+		return
+	}
+	if packet.Type != "sessionTakeover" || packet.Conn == p.connID {
+		return
+	}
+	if packet.StartedAt.Before(p.sessionStartedAt) || (packet.StartedAt.Equal(p.sessionStartedAt) && packet.Conn < p.connID) {
+		// This connection is newer, it already took over the peer (or will, once its own message arrives).
+		return
+	}
+
+	logger.Info("closing connection, session taken over", zap.String("peer", p.ID), zap.String("reason", packet.Reason))
+	p.sessionTakenOver.Store(true)
+
+	util.ReplyError(p.wireContext(ctx), p.conn, util.ErrorWithCode(fmt.Errorf("this session was taken over by another connection"), packet.Reason))
+	p.conn.Close(websocket.StatusPolicyViolation, packet.Reason) // nolint:errcheck
+}
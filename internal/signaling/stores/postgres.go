@@ -1,8 +1,10 @@
 package stores
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -10,6 +12,7 @@ import (
 	"regexp"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -38,7 +41,7 @@ type PostgresStore struct {
 
 func NewPostgresStore(ctx context.Context, db *pgxpool.Pool) (*PostgresStore, error) {
 	filterConverter, err := filter.NewConverter(
-		filter.WithNestedJSONB("custom_data", "code", "playerCount", "createdAt", "updatedAt", "latency"),
+		filter.WithNestedJSONB("custom_data", "code", "playerCount", "createdAt", "updatedAt", "latency", "state"),
 		filter.WithEmptyCondition("TRUE"), // No filter returns all lobbies.
 	)
 	if err != nil {
@@ -116,17 +119,24 @@ func (s *PostgresStore) notify(ctx context.Context, topic string, data []byte) {
 }
 
 func (s *PostgresStore) Subscribe(ctx context.Context, callback SubscriptionCallback, game, lobby, peerID string) {
+	s.subscribe(ctx, callback,
+		game+lobby+peerID, // Topic for a specific peer in a specific lobby.
+		game+lobby,        // Topic for all peers in a specific lobby.
+	)
+}
+
+func (s *PostgresStore) SubscribeSession(ctx context.Context, callback SubscriptionCallback, game, peerID string) {
+	s.subscribe(ctx, callback, SessionTopic(game, peerID))
+}
+
+// subscribe calls callback for messages published to topics until ctx is done.
+func (s *PostgresStore) subscribe(ctx context.Context, callback SubscriptionCallback, topics ...string) {
 	s.mutex.Lock()
 	defer s.mutex.Unlock()
 
 	id := s.nextCallbackIndex
 	s.nextCallbackIndex += 1
 
-	topics := []string{
-		game + lobby + peerID, // Topic for a specific peer in a specific lobby.
-		game + lobby,          // Topic for all peers in a specific lobby.
-	}
-
 	for _, topic := range topics {
 		if _, found := s.callbacks[topic]; !found {
 			s.callbacks[topic] = make(map[uint64]SubscriptionCallback)
@@ -153,26 +163,312 @@ func (s *PostgresStore) Subscribe(ctx context.Context, callback SubscriptionCall
 }
 
 func (s *PostgresStore) Publish(ctx context.Context, topic string, data []byte) error {
+	payload, err := notifyPayload(topic, data)
+	if err != nil {
+		return err
+	}
+	_, err = s.DB.Exec(ctx, `NOTIFY lobbies, '`+payload+`'`)
+	if err != nil {
+		return fmt.Errorf("failed to publish to lobbies: %w", err)
+	}
+	return nil
+}
+
+func notifyPayload(topic string, data []byte) (string, error) {
 	if !topicRegexp.MatchString(topic) {
-		return fmt.Errorf("topic %q is invalid", topic)
+		return "", fmt.Errorf("topic %q is invalid", topic)
 	}
 
 	compressedData, err := util.GzipCompress(data)
 	if err != nil {
-		return fmt.Errorf("failed to gzip data: %w", err)
+		return "", fmt.Errorf("failed to gzip data: %w", err)
 	}
 
 	totalLength := base64.StdEncoding.EncodedLen(len(compressedData)) + len(topic) + 1
 	if totalLength > 8000 {
-		return fmt.Errorf("data too long for topic %q: %d", topic, totalLength)
+		return "", fmt.Errorf("data too long for topic %q: %d", topic, totalLength)
 	}
 	encoded := base64.StdEncoding.EncodeToString(compressedData)
-	payload := topic + ":" + encoded
-	_, err = s.DB.Exec(ctx, `NOTIFY lobbies, '`+payload+`'`)
+	return topic + ":" + encoded, nil
+}
+
+func (s *PostgresStore) PublishLobbyEvent(ctx context.Context, game, lobbyCode string, data []byte) (int64, error) {
+	tx, err := s.DB.Begin(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to publish to lobbies: %w", err)
+		return 0, err
 	}
-	return nil
+	defer tx.Rollback(context.Background()) //nolint:errcheck
+
+	// Incrementing the sequence number locks the lobby until the transaction is committed,
+	// so events are logged and notified in the order of their sequence numbers.
+	var seq int64
+	var eventLog bool
+	err = tx.QueryRow(ctx, `
+		UPDATE lobbies
+		SET event_seq = event_seq + 1
+		WHERE game = $1
+		AND code = $2
+		RETURNING event_seq, event_log
+	`, game, lobbyCode).Scan(&seq, &eventLog)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// The lobby was deleted, there is no log to add the event to.
+		return 0, s.Publish(ctx, game+lobbyCode, data)
+	} else if err != nil {
+		return 0, err
+	}
+
+	data = withSeq(data, "seq", seq)
+	if eventLog {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO lobby_events (game, lobby, seq, data, created_at)
+			VALUES ($1, $2, $3, $4, $5)
+		`, game, lobbyCode, seq, data, util.NowUTC(ctx))
+		if err != nil {
+			return 0, err
+		}
+	}
+	// The log is trimmed once every LobbyEventLogSize events instead of for every event.
+	if eventLog && seq%LobbyEventLogSize == 0 {
+		_, err = tx.Exec(ctx, `
+			DELETE FROM lobby_events
+			WHERE game = $1
+			AND lobby = $2
+			AND seq <= $3
+		`, game, lobbyCode, seq-LobbyEventLogSize)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	// Notifications are only sent when the transaction is committed.
+	payload, err := notifyPayload(game+lobbyCode, data)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `NOTIFY lobbies, '`+payload+`'`); err != nil {
+		return 0, fmt.Errorf("failed to publish to lobbies: %w", err)
+	}
+
+	return seq, tx.Commit(ctx)
+}
+
+// withSeq adds a field with the sequence number to the json object in data.
+func withSeq(data []byte, name string, seq int64) []byte {
+	if len(data) < 2 || data[0] != '{' {
+		return data
+	}
+	field := `"` + name + `":` + strconv.FormatInt(seq, 10)
+	if rest := bytes.TrimSpace(data[1:]); len(rest) > 0 && rest[0] != '}' {
+		field += ","
+	}
+	return append([]byte("{"+field), data[1:]...)
+}
+
+func (s *PostgresStore) PublishToPeer(ctx context.Context, game, lobbyCode, peerID string, data []byte) (int64, error) {
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback(context.Background()) //nolint:errcheck
+
+	var seq int64
+	err = tx.QueryRow(ctx, `
+		UPDATE peers
+		SET outbox_seq = outbox_seq + 1
+		WHERE peer = $1
+		AND acks
+		AND EXISTS (
+			SELECT 1
+			FROM lobbies
+			WHERE game = $2
+			AND code = $3
+			AND $1 = ANY(peers)
+		)
+		RETURNING outbox_seq
+	`, peerID, game, lobbyCode).Scan(&seq)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Only peers in the lobby that ack get messages in their outbox.
+		return 0, s.Publish(ctx, game+lobbyCode+peerID, data)
+	} else if err != nil {
+		return 0, err
+	}
+
+	data = withSeq(data, "msgSeq", seq)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO peer_outbox (peer, seq, data, created_at)
+		VALUES ($1, $2, $3, $4)
+	`, peerID, seq, data, util.NowUTC(ctx))
+	if err != nil {
+		return 0, err
+	}
+	_, err = tx.Exec(ctx, `
+		DELETE FROM peer_outbox
+		WHERE peer = $1
+		AND seq <= $2
+	`, peerID, seq-PeerOutboxSize)
+	if err != nil {
+		return 0, err
+	}
+
+	payload, err := notifyPayload(game+lobbyCode+peerID, data)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := tx.Exec(ctx, `NOTIFY lobbies, '`+payload+`'`); err != nil {
+		return 0, fmt.Errorf("failed to publish to lobbies: %w", err)
+	}
+
+	return seq, tx.Commit(ctx)
+}
+
+func (s *PostgresStore) AckPeerMessages(ctx context.Context, peerID string, seq int64) error {
+	_, err := s.DB.Exec(ctx, `
+		DELETE FROM peer_outbox
+		WHERE peer = $1
+		AND seq <= $2
+	`, peerID, seq)
+	return err
+}
+
+func (s *PostgresStore) CreateTransferToken(ctx context.Context, peerID, token string, expiresAt time.Time) error {
+	now := util.NowUTC(ctx)
+
+	// Expired tokens can't be used anymore, clean them up while we're here.
+	_, err := s.DB.Exec(ctx, `
+		DELETE FROM peer_transfer_tokens
+		WHERE expires_at < $1
+	`, now)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.DB.Exec(ctx, `
+		INSERT INTO peer_transfer_tokens (token, peer, expires_at)
+		VALUES ($1, $2, $3)
+	`, token, peerID, expiresAt)
+	return err
+}
+
+func (s *PostgresStore) ClaimTransferToken(ctx context.Context, game, token, secret string) (string, error) {
+	now := util.NowUTC(ctx)
+
+	var peerID string
+	err := s.DB.QueryRow(ctx, `
+		WITH t AS (
+			DELETE FROM peer_transfer_tokens
+			WHERE token = $1
+			RETURNING peer, expires_at
+		)
+		UPDATE peers
+		SET
+			secret = $3,
+			updated_at = $4
+		FROM t
+		WHERE peers.peer = t.peer
+		  AND peers.game = $2
+		  AND t.expires_at >= $4
+		RETURNING peers.peer
+	`, token, game, secret, now).Scan(&peerID)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return "", ErrInvalidTransferToken
+		}
+		return "", err
+	}
+	return peerID, nil
+}
+
+func (s *PostgresStore) GetPeerMessages(ctx context.Context, peerID string) ([]json.RawMessage, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT data
+		FROM peer_outbox
+		WHERE peer = $1
+		ORDER BY seq
+	`, peerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var messages []json.RawMessage
+	for rows.Next() {
+		var data json.RawMessage
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		messages = append(messages, data)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return messages, nil
+}
+
+func (s *PostgresStore) EnableLobbyEventLog(ctx context.Context, game, lobbyCode string) error {
+	_, err := s.DB.Exec(ctx, `
+		UPDATE lobbies
+		SET event_log = TRUE
+		WHERE game = $1
+		AND code = $2
+		AND NOT event_log
+	`, game, lobbyCode)
+	return err
+}
+
+func (s *PostgresStore) GetLobbyEvents(ctx context.Context, game, lobbyCode string, since int64) ([]json.RawMessage, int64, bool, error) {
+	tx, err := s.DB.BeginTx(ctx, pgx.TxOptions{AccessMode: pgx.ReadOnly, IsoLevel: pgx.RepeatableRead})
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer tx.Rollback(context.Background()) //nolint:errcheck
+
+	var seq int64
+	err = tx.QueryRow(ctx, `
+		SELECT event_seq
+		FROM lobbies
+		WHERE game = $1
+		AND code = $2
+	`, game, lobbyCode).Scan(&seq)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, 0, false, ErrNotFound
+	} else if err != nil {
+		return nil, 0, false, err
+	}
+	if since >= seq {
+		return nil, seq, true, nil
+	}
+
+	rows, err := tx.Query(ctx, `
+		SELECT seq, data
+		FROM lobby_events
+		WHERE game = $1
+		AND lobby = $2
+		AND seq > $3
+		ORDER BY seq
+	`, game, lobbyCode, since)
+	if err != nil {
+		return nil, 0, false, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var events []json.RawMessage
+	complete := false
+	for rows.Next() {
+		var eventSeq int64
+		var data json.RawMessage
+		if err := rows.Scan(&eventSeq, &data); err != nil {
+			return nil, 0, false, err
+		}
+		if len(events) == 0 {
+			// Events before the oldest event in the log are lost.
+			complete = eventSeq == since+1
+		}
+		events = append(events, data)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, 0, false, err
+	}
+	return events, seq, complete, nil
 }
 
 func (s *PostgresStore) CreateLobby(ctx context.Context, game, lobbyCode, peerID string, options LobbyOptions) error {
@@ -197,19 +493,67 @@ func (s *PostgresStore) CreateLobby(ctx context.Context, game, lobbyCode, peerID
 		}
 	}
 
+	if options.ValidateCustomData != nil {
+		var customData map[string]any
+		if options.CustomData != nil {
+			customData = *options.CustomData
+		}
+		if err := options.ValidateCustomData(customData); err != nil {
+			return err
+		}
+	}
+
+	var idleTimeout *int
+	if options.IdleTimeout != nil && *options.IdleTimeout > 0 {
+		seconds := int(options.IdleTimeout.Seconds())
+		idleTimeout = &seconds
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background()) //nolint:errcheck
+
+	if options.MaxLobbies > 0 {
+		count, err := countLocked(ctx, tx, "lobbies:"+game, `
+			SELECT COUNT(*)
+			FROM lobbies
+			WHERE game = $1
+			AND peers <> '{}'
+		`, game)
+		if err != nil {
+			return err
+		}
+		if count >= options.MaxLobbies {
+			return ErrTooManyLobbies
+		}
+	}
+
 	now := util.NowUTC(ctx)
-	res, err := s.DB.Exec(ctx, `
-		INSERT INTO lobbies (code, game, peers, public, custom_data, created_at, updated_at, leader, term, can_update_by, creator, password, max_players)
-		VALUES ($1, $2, $3, $4, $5, $6, $6, $7, 1, $8, $7, $9, $10)
+	res, err := tx.Exec(ctx, `
+		INSERT INTO lobbies (code, game, peers, public, custom_data, created_at, updated_at, leader, term, can_update_by, creator, password, max_players, expires_at, idle_timeout, reconnect_policy)
+		VALUES ($1, $2, $3, $4, $5, $6, $6, $7, 1, $8, $7, $9, $10, $11, $12, COALESCE($13, 'hold'))
 		ON CONFLICT DO NOTHING
-	`, lobbyCode, game, []string{peerID}, options.Public, options.CustomData, now, peerID, options.CanUpdateBy, hashedPassword, options.MaxPlayers)
+	`, lobbyCode, game, []string{peerID}, options.Public, options.CustomData, now, peerID, options.CanUpdateBy, hashedPassword, options.MaxPlayers, options.ExpiresAt, idleTimeout, options.ReconnectPolicy)
 	if err != nil {
 		return err
 	}
 	if res.RowsAffected() == 0 {
 		return ErrLobbyExists
 	}
-	return nil
+	return tx.Commit(ctx)
+}
+
+// countLocked takes a transaction level advisory lock on key and then runs the count query.
+// Inserts that check a limit with it are serialized, so they can't all see room for one more row.
+func countLocked(ctx context.Context, tx pgx.Tx, key, query string, args ...any) (int, error) {
+	if _, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext($1))`, key); err != nil {
+		return 0, err
+	}
+	var count int
+	err := tx.QueryRow(ctx, query, args...).Scan(&count)
+	return count, err
 }
 
 func (s *PostgresStore) JoinLobby(ctx context.Context, game, lobbyCode, peerID, password string) ([]string, error) {
@@ -276,21 +620,32 @@ func (s *PostgresStore) JoinLobby(ctx context.Context, game, lobbyCode, peerID,
 	return peerlist, nil
 }
 
-func (s *PostgresStore) LeaveLobby(ctx context.Context, game, lobbyCode, peerID string) error {
+func (s *PostgresStore) LeaveLobby(ctx context.Context, game, lobbyCode, peerID string) (bool, error) {
 	now := util.NowUTC(ctx)
 
-	_, err := s.DB.Exec(ctx, `
+	// The old row is locked and read in a subquery, RETURNING only sees the updated row.
+	var wasReady bool
+	err := s.DB.QueryRow(ctx, `
 		UPDATE lobbies
 		SET
-			peers = array_remove(peers, $1),
+			peers = array_remove(lobbies.peers, $1),
+			ready = array_remove(lobbies.ready, $1),
 			updated_at = $2
-		WHERE code = $3
-		AND game = $4
-	`, peerID, now, lobbyCode, game)
+		FROM (
+			SELECT code, game, $1 = ANY(ready) AS was_ready
+			FROM lobbies
+			WHERE code = $3
+			AND game = $4
+			FOR UPDATE
+		) AS old
+		WHERE lobbies.code = old.code
+		AND lobbies.game = old.game
+		RETURNING old.was_ready
+	`, peerID, now, lobbyCode, game).Scan(&wasReady)
 	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
-		return err
+		return false, err
 	}
-	return nil
+	return wasReady, nil
 }
 
 func (s *PostgresStore) GetLobby(ctx context.Context, game, lobbyCode string) (Lobby, error) {
@@ -309,11 +664,19 @@ func (s *PostgresStore) GetLobby(ctx context.Context, game, lobbyCode string) (L
 			can_update_by,
 			creator,
 			password IS NOT NULL,
-			max_players
+			max_players,
+			state,
+			ready,
+			persistent,
+			version,
+			event_seq,
+			expires_at,
+			COALESCE(idle_timeout, 0),
+			reconnect_policy
 		FROM lobbies
 		WHERE code = $1
 		AND game = $2
-	`, lobbyCode, game).Scan(&lobby.Code, &lobby.Peers, &lobby.PlayerCount, &lobby.Public, &lobby.CustomData, &lobby.CreatedAt, &lobby.UpdatedAt, &lobby.Leader, &lobby.Term, &lobby.CanUpdateBy, &lobby.Creator, &lobby.HasPassword, &lobby.MaxPlayers)
+	`, lobbyCode, game).Scan(&lobby.Code, &lobby.Peers, &lobby.PlayerCount, &lobby.Public, &lobby.CustomData, &lobby.CreatedAt, &lobby.UpdatedAt, &lobby.Leader, &lobby.Term, &lobby.CanUpdateBy, &lobby.Creator, &lobby.HasPassword, &lobby.MaxPlayers, &lobby.State, &lobby.Ready, &lobby.Persistent, &lobby.Version, &lobby.Seq, &lobby.ExpiresAt, &lobby.IdleTimeout, &lobby.ReconnectPolicy)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
 			return Lobby{}, ErrNotFound
@@ -321,10 +684,52 @@ func (s *PostgresStore) GetLobby(ctx context.Context, game, lobbyCode string) (L
 		return Lobby{}, err
 	}
 	sort.Strings(lobby.Peers)
+	sort.Strings(lobby.Ready)
+
+	lobby.PeerInfo, err = s.getPeerInfo(ctx, game, lobby.Peers)
+	if err != nil {
+		return Lobby{}, err
+	}
 	return lobby, nil
 }
 
-func (s *PostgresStore) ListLobbies(ctx context.Context, game string, country, region string, filter, sort string, limit int) ([]Lobby, error) {
+// getPeerInfo returns the public information of the given peers, keyed by peer ID.
+func (s *PostgresStore) getPeerInfo(ctx context.Context, game string, peerIDs []string) (map[string]PeerInfo, error) {
+	if len(peerIDs) == 0 {
+		return nil, nil
+	}
+
+	rows, err := s.DB.Query(ctx, `
+		SELECT
+			peer,
+			COALESCE(user_id, ''),
+			COALESCE(display_name, ''),
+			custom_data
+		FROM peers
+		WHERE game = $1
+		AND peer = ANY($2)
+	`, game, peerIDs)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	info := make(map[string]PeerInfo, len(peerIDs))
+	for rows.Next() {
+		var peer PeerInfo
+		if err := rows.Scan(&peer.ID, &peer.UserID, &peer.DisplayName, &peer.CustomData); err != nil {
+			return nil, err
+		}
+		info[peer.ID] = peer
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return info, nil
+}
+
+// ListLobbies lists the public lobbies of a game. Lobbies that are in-game are only included when includeInGame is set.
+func (s *PostgresStore) ListLobbies(ctx context.Context, game string, country, region string, filter, sort string, limit int, includeInGame bool) ([]Lobby, error) {
 	// TODO: Remove this.
 	if filter == "" {
 		filter = "{}"
@@ -334,7 +739,7 @@ func (s *PostgresStore) ListLobbies(ctx context.Context, game string, country, r
 		limit = 50
 	}
 
-	preValues := []any{game, country, region, limit}
+	preValues := []any{game, country, region, limit, includeInGame}
 
 	where, values, err := s.filterConverter.Convert([]byte(filter), len(preValues)+1)
 	if err != nil {
@@ -374,10 +779,17 @@ func (s *PostgresStore) ListLobbies(ctx context.Context, game string, country, r
 				creator,
 				password IS NOT NULL,
 				max_players,
-				lobby_latency_estimate(peers, $2, $3) AS latency
+				lobby_latency_estimate(peers, $2, $3) AS latency,
+				state,
+				persistent,
+				version,
+				expires_at AS "expiresAt",
+				COALESCE(idle_timeout, 0) AS "idleTimeout",
+				reconnect_policy AS "reconnectPolicy"
 			FROM lobbies
 			WHERE game = $1
 			  AND public = true
+			  AND (state <> 'in-game' OR $5)
 		)
 		SELECT *
 		FROM game_lobbies
@@ -392,7 +804,7 @@ func (s *PostgresStore) ListLobbies(ctx context.Context, game string, country, r
 
 	for rows.Next() {
 		var lobby Lobby
-		err = rows.Scan(&lobby.Code, &lobby.PlayerCount, &lobby.Public, &lobby.CustomData, &lobby.CreatedAt, &lobby.UpdatedAt, &lobby.Leader, &lobby.Term, &lobby.CanUpdateBy, &lobby.Creator, &lobby.HasPassword, &lobby.MaxPlayers, &lobby.Latency)
+		err = rows.Scan(&lobby.Code, &lobby.PlayerCount, &lobby.Public, &lobby.CustomData, &lobby.CreatedAt, &lobby.UpdatedAt, &lobby.Leader, &lobby.Term, &lobby.CanUpdateBy, &lobby.Creator, &lobby.HasPassword, &lobby.MaxPlayers, &lobby.Latency, &lobby.State, &lobby.Persistent, &lobby.Version, &lobby.ExpiresAt, &lobby.IdleTimeout, &lobby.ReconnectPolicy)
 		if err != nil {
 			return nil, err
 		}
@@ -405,22 +817,60 @@ func (s *PostgresStore) ListLobbies(ctx context.Context, game string, country, r
 	return lobbies, nil
 }
 
-func (s *PostgresStore) CreatePeer(ctx context.Context, peerID, secret, gameID string) error {
+func (s *PostgresStore) CreatePeer(ctx context.Context, peerID, secret, gameID string, disconnectThreshold time.Duration, maxPeers int) error {
 	if len(peerID) > 20 {
 		logger := logging.GetLogger(ctx)
 		logger.Warn("peer id too long", zap.String("peerID", peerID))
 		return ErrInvalidPeerID
 	}
 
+	var threshold *int
+	if disconnectThreshold > 0 {
+		seconds := int(disconnectThreshold.Seconds())
+		threshold = &seconds
+	}
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(context.Background()) //nolint:errcheck
+
+	if maxPeers > 0 {
+		// Disconnected peers that can still reconnect don't count towards the limit.
+		count, err := countLocked(ctx, tx, "peers:"+gameID, `
+			SELECT COUNT(*)
+			FROM peers
+			WHERE game = $1
+			AND disconnected = FALSE
+		`, gameID)
+		if err != nil {
+			return err
+		}
+		if count >= maxPeers {
+			return ErrTooManyPeers
+		}
+	}
+
 	now := util.NowUTC(ctx)
-	_, err := s.DB.Exec(ctx, `
-		INSERT INTO peers (peer, secret, game, last_seen, updated_at)
-		VALUES ($1, $2, $3, $4, $4)
-	`, peerID, secret, gameID, now)
+	_, err = tx.Exec(ctx, `
+		INSERT INTO peers (peer, secret, game, last_seen, updated_at, disconnect_threshold)
+		VALUES ($1, $2, $3, $4, $4, $5)
+	`, peerID, secret, gameID, now, threshold)
 	if err != nil {
 		return err
 	}
-	return nil
+	return tx.Commit(ctx)
+}
+
+func (s *PostgresStore) UpdatePeerAcks(ctx context.Context, peerID string, acks bool) error {
+	_, err := s.DB.Exec(ctx, `
+		UPDATE peers
+		SET acks = $1
+		WHERE peer = $2
+		AND acks <> $1
+	`, acks, peerID)
+	return err
 }
 
 func (s *PostgresStore) UpdatePeerGeo(ctx context.Context, peerID string, country, region string) error {
@@ -440,6 +890,45 @@ func (s *PostgresStore) UpdatePeerGeo(ctx context.Context, peerID string, countr
 	return err
 }
 
+func (s *PostgresStore) UpdatePeerIdentity(ctx context.Context, peerID string, userID, displayName string) error {
+	now := util.NowUTC(ctx)
+
+	_, err := s.DB.Exec(ctx, `
+		UPDATE peers
+		SET
+			user_id = NULLIF($1, ''),
+			display_name = NULLIF($2, ''),
+			updated_at = $3
+		WHERE peer = $4
+	`, userID, displayName, now, peerID)
+	return err
+}
+
+func (s *PostgresStore) UpdatePeerCustomData(ctx context.Context, peerID string, customData map[string]any) error {
+	now := util.NowUTC(ctx)
+
+	_, err := s.DB.Exec(ctx, `
+		UPDATE peers
+		SET
+			custom_data = $1,
+			updated_at = $2
+		WHERE peer = $3
+	`, customData, now, peerID)
+	return err
+}
+
+func (s *PostgresStore) GetPeerInfo(ctx context.Context, gameID, peerID string) (PeerInfo, error) {
+	info, err := s.getPeerInfo(ctx, gameID, []string{peerID})
+	if err != nil {
+		return PeerInfo{}, err
+	}
+	peer, found := info[peerID]
+	if !found {
+		return PeerInfo{}, ErrPeerNotFound
+	}
+	return peer, nil
+}
+
 func (s *PostgresStore) MarkPeerAsActive(ctx context.Context, peerID string) error {
 	now := util.NowUTC(ctx)
 
@@ -454,17 +943,71 @@ func (s *PostgresStore) MarkPeerAsActive(ctx context.Context, peerID string) err
 	return err
 }
 
-func (s *PostgresStore) MarkPeerAsDisconnected(ctx context.Context, peerID string) error {
+func (s *PostgresStore) MarkPeerAsDisconnected(ctx context.Context, peerID string) ([]string, []string, error) {
 	now := util.NowUTC(ctx)
 
-	_, err := s.DB.Exec(ctx, `
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback(context.Background()) //nolint:errcheck
+
+	_, err = tx.Exec(ctx, `
 		UPDATE peers
 		SET
 			disconnected = TRUE,
 			updated_at = $1
 		WHERE peer = $2
 	`, now, peerID)
-	return err
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var lobbies, wasReady []string
+	rows, err := tx.Query(ctx, `
+		UPDATE lobbies
+		SET
+			peers = array_remove(lobbies.peers, $1),
+			ready = array_remove(lobbies.ready, $1),
+			updated_at = $2
+		FROM (
+			SELECT code, game, $1 = ANY(ready) AS was_ready
+			FROM lobbies
+			WHERE $1 = ANY(peers)
+			  AND reconnect_policy = 'free'
+			FOR UPDATE
+		) AS old
+		WHERE lobbies.code = old.code
+		AND lobbies.game = old.game
+		RETURNING lobbies.code, old.was_ready
+	`, peerID, now)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for rows.Next() {
+		var lobby string
+		var ready bool
+
+		if err := rows.Scan(&lobby, &ready); err != nil {
+			return nil, nil, err
+		}
+
+		lobbies = append(lobbies, lobby)
+		if ready {
+			wasReady = append(wasReady, lobby)
+		}
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	if err = tx.Commit(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	return lobbies, wasReady, nil
 }
 
 func (s *PostgresStore) MarkPeerAsReconnected(ctx context.Context, peerID, secret, gameID string) (bool, []string, error) {
@@ -516,12 +1059,12 @@ func (s *PostgresStore) MarkPeerAsReconnected(ctx context.Context, peerID, secre
 	return true, lobbies, nil
 }
 
-func (s *PostgresStore) ClaimNextTimedOutPeer(ctx context.Context, threshold time.Duration) (string, bool, map[string][]string, error) {
+func (s *PostgresStore) ClaimNextTimedOutPeer(ctx context.Context, threshold time.Duration) (string, bool, map[string][]string, []string, error) {
 	now := util.NowUTC(ctx)
 
 	tx, err := s.DB.Begin(ctx)
 	if err != nil {
-		return "", false, nil, err
+		return "", false, nil, nil, err
 	}
 	defer tx.Rollback(context.Background()) //nolint:errcheck
 
@@ -531,56 +1074,69 @@ func (s *PostgresStore) ClaimNextTimedOutPeer(ctx context.Context, threshold tim
 		WITH d AS (
 			SELECT peer, disconnected
 			FROM peers
-			WHERE last_seen < $1
+			WHERE last_seen < $1 - make_interval(secs => COALESCE(disconnect_threshold, $2))
 			LIMIT 1
 		)
 		DELETE FROM peers
 		USING d
 		WHERE peers.peer = d.peer
 		RETURNING d.peer, d.disconnected
-	`, now.Add(-threshold)).Scan(&peerID, &disconnected)
+	`, now, int(threshold.Seconds())).Scan(&peerID, &disconnected)
 	if err != nil {
 		if errors.Is(err, pgx.ErrNoRows) {
-			return "", false, nil, nil
+			return "", false, nil, nil, nil
 		}
-		return "", false, nil, err
+		return "", false, nil, nil, err
 	}
 
 	gameLobbies := make(map[string][]string)
 
+	var wasReady []string
 	rows, err := tx.Query(ctx, `
 		UPDATE lobbies
 		SET
-			peers = array_remove(peers, $1),
+			peers = array_remove(lobbies.peers, $1),
+			ready = array_remove(lobbies.ready, $1),
 			updated_at = $2
-		WHERE $1 = ANY(peers)
-		RETURNING game, code
+		FROM (
+			SELECT code, game, $1 = ANY(ready) AS was_ready
+			FROM lobbies
+			WHERE $1 = ANY(peers)
+			FOR UPDATE
+		) AS old
+		WHERE lobbies.code = old.code
+		AND lobbies.game = old.game
+		RETURNING lobbies.game, lobbies.code, old.was_ready
 	`, peerID, now)
 	if err != nil {
-		return "", false, nil, err
+		return "", false, nil, nil, err
 	}
 
 	for rows.Next() {
 		var game string
 		var lobby string
+		var ready bool
 
-		err = rows.Scan(&game, &lobby)
+		err = rows.Scan(&game, &lobby, &ready)
 		if err != nil {
-			return "", false, nil, err
+			return "", false, nil, nil, err
 		}
 
 		gameLobbies[game] = append(gameLobbies[game], lobby)
+		if ready {
+			wasReady = append(wasReady, lobby)
+		}
 	}
 
 	if err = rows.Err(); err != nil {
-		return "", false, nil, err
+		return "", false, nil, nil, err
 	}
 
 	if err = tx.Commit(ctx); err != nil {
-		return "", false, nil, err
+		return "", false, nil, nil, err
 	}
 
-	return peerID, disconnected, gameLobbies, nil
+	return peerID, disconnected, gameLobbies, wasReady, nil
 }
 
 // ResetAllPeerLastSeen will reset all last_seen.
@@ -598,15 +1154,127 @@ func (s *PostgresStore) ResetAllPeerLastSeen(ctx context.Context) error {
 	return err
 }
 
-func (s *PostgresStore) CleanEmptyLobbies(ctx context.Context, olderThan time.Time) error {
+func (s *PostgresStore) CleanEmptyLobbies(ctx context.Context, olderThan time.Time, game string, skipGames []string) error {
+	if skipGames == nil {
+		skipGames = []string{}
+	}
 	_, err := s.DB.Exec(ctx, `
 		DELETE FROM lobbies
 		WHERE updated_at < $1
 		AND peers = '{}'
-	`, olderThan)
+		AND NOT persistent
+		AND ($2 = '' OR game::text = $2)
+		AND game::text <> ALL($3)
+	`, olderThan, game, skipGames)
 	return err
 }
 
+func (s *PostgresStore) UpsertPersistentLobby(ctx context.Context, game, lobbyCode string, options LobbyOptions) error {
+	if len(lobbyCode) > 20 {
+		return ErrInvalidLobbyCode
+	}
+
+	var hashedPassword []byte
+	if options.Password != nil && len(*options.Password) > 0 {
+		var err error
+		hashedPassword, err = bcrypt.GenerateFromPassword([]byte(*options.Password), bcrypt.DefaultCost)
+		if err != nil {
+			return err
+		}
+	}
+
+	canUpdateBy := CanUpdateByNone
+	if options.CanUpdateBy != nil && *options.CanUpdateBy != "" {
+		canUpdateBy = *options.CanUpdateBy
+	}
+
+	now := util.NowUTC(ctx)
+	res, err := s.DB.Exec(ctx, `
+		INSERT INTO lobbies (code, game, peers, public, custom_data, created_at, updated_at, term, can_update_by, password, max_players, persistent)
+		VALUES ($1, $2, '{}', COALESCE($3, false), $4, $5, $5, 1, $6, $7, $8, true)
+		ON CONFLICT (code, game) DO UPDATE
+		SET
+			public = COALESCE($3, lobbies.public),
+			custom_data = COALESCE($4, lobbies.custom_data),
+			updated_at = $5,
+			can_update_by = $6,
+			password = $7,
+			max_players = COALESCE($8, lobbies.max_players),
+			persistent = true,
+			expires_at = NULL,
+			idle_timeout = NULL
+		WHERE lobbies.persistent
+	`, lobbyCode, game, options.Public, options.CustomData, now, canUpdateBy, hashedPassword, options.MaxPlayers)
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		// A lobby created by a client has the code, it isn't taken over.
+		return ErrLobbyExists
+	}
+	return nil
+}
+
+func (s *PostgresStore) UnsetPersistentLobby(ctx context.Context, game, lobbyCode string) error {
+	res, err := s.DB.Exec(ctx, `
+		UPDATE lobbies
+		SET
+			persistent = false,
+			updated_at = $3
+		WHERE code = $1
+		AND game = $2
+	`, lobbyCode, game, util.NowUTC(ctx))
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *PostgresStore) ClaimExpiredLobbies(ctx context.Context, limit int) ([]ExpiredLobby, error) {
+	now := util.NowUTC(ctx)
+
+	// SKIP LOCKED makes sure multiple instances don't claim the same lobbies.
+	rows, err := s.DB.Query(ctx, `
+		WITH expired AS (
+			SELECT game, code
+			FROM lobbies
+			WHERE expires_at < $1
+			OR (idle_timeout IS NOT NULL AND updated_at < $1 - make_interval(secs => idle_timeout))
+			LIMIT $2
+			FOR UPDATE SKIP LOCKED
+		)
+		DELETE FROM lobbies
+		USING expired
+		WHERE lobbies.game = expired.game
+		AND lobbies.code = expired.code
+		RETURNING
+			lobbies.game,
+			lobbies.code,
+			lobbies.peers,
+			CASE WHEN lobbies.expires_at < $1 THEN $3 ELSE $4 END
+	`, now, limit, ExpiredReasonTTL, ExpiredReasonIdle)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	var lobbies []ExpiredLobby
+	for rows.Next() {
+		var lobby ExpiredLobby
+		if err := rows.Scan(&lobby.Game, &lobby.Code, &lobby.Peers, &lobby.Reason); err != nil {
+			return nil, err
+		}
+		lobbies = append(lobbies, lobby)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return lobbies, nil
+}
+
 // DoLeaderElection attempts to elect a leader for the given lobby. If a correct leader already exists it will return nil.
 // If no leader can be elected, it will return an ElectionResult with a nil leader.
 func (s *PostgresStore) DoLeaderElection(ctx context.Context, gameID, lobbyCode string) (*ElectionResult, error) {
@@ -725,30 +1393,46 @@ func (s *PostgresStore) UpdateLobby(ctx context.Context, game, lobbyCode, peerID
 	var leader string
 	var currentCanUpdateBy string
 	var creator string
+	var currentState string
+	var version int
+	var updatedAt time.Time
 	err = tx.QueryRow(ctx, `
-		SELECT leader, can_update_by, creator
+		SELECT leader, can_update_by, creator, state, version, updated_at
 		FROM lobbies
 		WHERE game = $1
 		AND code = $2
 		FOR UPDATE
-	`, game, lobbyCode).Scan(&leader, &currentCanUpdateBy, &creator)
+	`, game, lobbyCode).Scan(&leader, &currentCanUpdateBy, &creator, &currentState, &version, &updatedAt)
 	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return ErrNotFound
+		}
 		return err
 	}
 
+	if options.ExpectedVersion != nil && *options.ExpectedVersion != version {
+		return fmt.Errorf("%w: expected version %d, lobby is at version %d", ErrVersionConflict, *options.ExpectedVersion, version)
+	}
+	// Postgres stores timestamps with microsecond precision.
+	if options.ExpectedUpdatedAt != nil && !options.ExpectedUpdatedAt.Truncate(time.Microsecond).Equal(updatedAt.Truncate(time.Microsecond)) {
+		return fmt.Errorf("%w: expected updatedAt %s, lobby was updated at %s", ErrVersionConflict, options.ExpectedUpdatedAt.Format(time.RFC3339Nano), updatedAt.Format(time.RFC3339Nano))
+	}
+
 	switch currentCanUpdateBy {
 	case CanUpdateByAnyone:
 		// No restrictions.
 	case CanUpdateByCreator:
 		if creator != peerID {
-			return errors.New("not allowed: peer is not the creator")
+			return fmt.Errorf("%w: peer is not the creator", ErrNotAllowed)
 		}
 	case CanUpdateByLeader:
 		if leader != peerID {
-			return errors.New("not allowed: peer is not the leader")
+			return fmt.Errorf("%w: peer is not the leader", ErrNotAllowed)
 		}
+	case CanUpdateByNone:
+		return fmt.Errorf("%w: the lobby can't be updated by peers", ErrNotAllowed)
 	default:
-		return fmt.Errorf("invalid can_update_by value: %q", currentCanUpdateBy)
+		return fmt.Errorf("%w: invalid can_update_by value: %q", ErrNotAllowed, currentCanUpdateBy)
 	}
 
 	columns := make([]string, 0, 3)
@@ -758,10 +1442,29 @@ func (s *PostgresStore) UpdateLobby(ctx context.Context, game, lobbyCode, peerID
 		columns = append(columns, fmt.Sprintf("public = $%d", len(values)+1))
 		values = append(values, *options.Public)
 	}
+	// The customData is replaced, patched, set and unset in that order. Patching in SQL
+	// makes sure concurrent updates of different keys don't overwrite each other.
+	customData := "custom_data"
 	if options.CustomData != nil {
-		columns = append(columns, fmt.Sprintf("custom_data = $%d", len(values)+1))
+		customData = fmt.Sprintf("$%d::jsonb", len(values)+1)
 		values = append(values, *options.CustomData)
 	}
+	if options.CustomDataPatch != nil {
+		customData = fmt.Sprintf("jsonb_merge_patch(%s, $%d::jsonb)", customData, len(values)+1)
+		values = append(values, options.CustomDataPatch)
+	}
+	if options.SetCustomData != nil {
+		customData = fmt.Sprintf("(COALESCE(%s, '{}'::jsonb) || $%d::jsonb)", customData, len(values)+1)
+		values = append(values, options.SetCustomData)
+	}
+	if len(options.UnsetCustomData) > 0 {
+		customData = fmt.Sprintf("(%s - $%d::text[])", customData, len(values)+1)
+		values = append(values, options.UnsetCustomData)
+	}
+	updatesCustomData := customData != "custom_data"
+	if updatesCustomData {
+		columns = append(columns, "custom_data = "+customData)
+	}
 	if options.CanUpdateBy != nil {
 		columns = append(columns, fmt.Sprintf("can_update_by = $%d", len(values)+1))
 		values = append(values, *options.CanUpdateBy)
@@ -783,20 +1486,227 @@ func (s *PostgresStore) UpdateLobby(ctx context.Context, game, lobbyCode, peerID
 		columns = append(columns, fmt.Sprintf("max_players = $%d", len(values)+1))
 		values = append(values, *options.MaxPlayers)
 	}
+	if options.ReconnectPolicy != nil {
+		columns = append(columns, fmt.Sprintf("reconnect_policy = $%d", len(values)+1))
+		values = append(values, *options.ReconnectPolicy)
+	}
+	if options.State != nil && *options.State != currentState {
+		if !CanTransitionLobbyState(currentState, *options.State) {
+			return fmt.Errorf("%w: from %s to %s", ErrInvalidStateTransition, currentState, *options.State)
+		}
+		// Ready flags are for the current state, so start over in the new state.
+		columns = append(columns, fmt.Sprintf("state = $%d", len(values)+1), "ready = '{}'")
+		values = append(values, *options.State)
+	}
 
 	if len(columns) == 0 {
 		return nil
 	}
+	columns = append(columns, "version = version + 1", fmt.Sprintf("updated_at = $%d", len(values)+1))
+	values = append(values, util.NowUTC(ctx))
 
-	_, err = tx.Exec(ctx, `
+	var newCustomData map[string]any
+	err = tx.QueryRow(ctx, `
 		UPDATE lobbies
 		SET `+strings.Join(columns, ", ")+`
 		WHERE game = $1
 		AND code = $2
-	`, values...)
+		RETURNING custom_data
+	`, values...).Scan(&newCustomData)
 	if err != nil {
 		return err
 	}
 
+	// The resulting customData is only known after patching, so validate it before committing.
+	if updatesCustomData && options.ValidateCustomData != nil {
+		if err := options.ValidateCustomData(newCustomData); err != nil {
+			return err
+		}
+	}
+
 	return tx.Commit(ctx)
 }
+
+func (s *PostgresStore) SetPeerReady(ctx context.Context, game, lobbyCode, peerID string, ready bool) ([]string, bool, error) {
+	now := util.NowUTC(ctx)
+
+	var readyPeers []string
+	var allReady bool
+	err := s.DB.QueryRow(ctx, `
+		UPDATE lobbies
+		SET
+			ready = CASE
+				WHEN $1 THEN array_append(array_remove(ready, $2), $2)
+				ELSE array_remove(ready, $2)
+			END,
+			updated_at = $3
+		WHERE game = $4
+		AND code = $5
+		AND $2 = ANY(peers)
+		RETURNING ready, peers <@ ready
+	`, ready, peerID, now, game, lobbyCode).Scan(&readyPeers, &allReady)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, false, ErrNotFound
+		}
+		return nil, false, err
+	}
+	sort.Strings(readyPeers)
+	return readyPeers, allReady, nil
+}
+
+func (s *PostgresStore) SetLobbyState(ctx context.Context, game, lobbyCode, peerID, key string, update StateUpdate) (StateEntry, error) {
+	now := util.NowUTC(ctx)
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return StateEntry{}, err
+	}
+	defer tx.Rollback(context.Background()) //nolint:errcheck
+
+	// Locking the lobby serializes all state changes of the lobby, this
+	// makes sure the number of keys can't be exceeded by concurrent inserts.
+	var leader string
+	err = tx.QueryRow(ctx, `
+		SELECT COALESCE(leader, '')
+		FROM lobbies
+		WHERE game = $1
+		AND code = $2
+		AND $3 = ANY(peers)
+		FOR UPDATE
+	`, game, lobbyCode, peerID).Scan(&leader)
+	if err != nil {
+		if errors.Is(err, pgx.ErrNoRows) {
+			return StateEntry{}, ErrNotFound
+		}
+		return StateEntry{}, err
+	}
+
+	var entry StateEntry
+	err = tx.QueryRow(ctx, `
+		SELECT value, owner, access, version, updated_at
+		FROM lobby_shared_state
+		WHERE game = $1
+		AND lobby = $2
+		AND key = $3
+	`, game, lobbyCode, key).Scan(&entry.Value, &entry.Owner, &entry.Access, &entry.Version, &entry.UpdatedAt)
+	exists := err == nil
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return StateEntry{}, err
+	}
+
+	if update.ExpectedVersion != nil && *update.ExpectedVersion != entry.Version {
+		return StateEntry{}, fmt.Errorf("%w: expected version %d of %s, it's at version %d", ErrVersionConflict, *update.ExpectedVersion, key, entry.Version)
+	}
+
+	if !exists {
+		if update.Deleted() {
+			return StateEntry{}, nil
+		}
+		access := update.Access
+		if access == "" {
+			access = StateAccessAnyone
+		}
+		if access == StateAccessLeader && peerID != leader {
+			return StateEntry{}, fmt.Errorf("%w: only the leader can create leader keys", ErrStateNotAllowed)
+		}
+		if update.MaxKeys > 0 {
+			var count int
+			err := tx.QueryRow(ctx, `
+				SELECT COUNT(*)
+				FROM lobby_shared_state
+				WHERE game = $1
+				AND lobby = $2
+			`, game, lobbyCode).Scan(&count)
+			if err != nil {
+				return StateEntry{}, err
+			}
+			if count >= update.MaxKeys {
+				return StateEntry{}, ErrTooManyStateKeys
+			}
+		}
+
+		entry = StateEntry{Value: update.Value, Owner: peerID, Access: access, Version: 1, UpdatedAt: now}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO lobby_shared_state (game, lobby, key, value, owner, access, version, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, 1, $7)
+		`, game, lobbyCode, key, entry.Value, entry.Owner, entry.Access, now)
+		if err != nil {
+			return StateEntry{}, err
+		}
+		return entry, tx.Commit(ctx)
+	}
+
+	switch entry.Access {
+	case StateAccessLeader:
+		if peerID != leader {
+			return StateEntry{}, fmt.Errorf("%w: only the leader can change %s", ErrStateNotAllowed, key)
+		}
+	case StateAccessOwner:
+		if peerID != entry.Owner {
+			return StateEntry{}, fmt.Errorf("%w: only the owner can change %s", ErrStateNotAllowed, key)
+		}
+	}
+	if update.Access != "" && update.Access != entry.Access {
+		if peerID != entry.Owner {
+			return StateEntry{}, fmt.Errorf("%w: only the owner can change the access of %s", ErrStateNotAllowed, key)
+		}
+		entry.Access = update.Access
+	}
+
+	entry.Version++
+	entry.UpdatedAt = now
+	if update.Deleted() {
+		entry.Value = nil
+		_, err = tx.Exec(ctx, `
+			DELETE FROM lobby_shared_state
+			WHERE game = $1
+			AND lobby = $2
+			AND key = $3
+		`, game, lobbyCode, key)
+	} else {
+		entry.Value = update.Value
+		_, err = tx.Exec(ctx, `
+			UPDATE lobby_shared_state
+			SET
+				value = $4,
+				access = $5,
+				version = $6,
+				updated_at = $7
+			WHERE game = $1
+			AND lobby = $2
+			AND key = $3
+		`, game, lobbyCode, key, entry.Value, entry.Access, entry.Version, now)
+	}
+	if err != nil {
+		return StateEntry{}, err
+	}
+	return entry, tx.Commit(ctx)
+}
+
+func (s *PostgresStore) GetLobbyState(ctx context.Context, game, lobbyCode string) (map[string]StateEntry, error) {
+	rows, err := s.DB.Query(ctx, `
+		SELECT key, value, owner, access, version, updated_at
+		FROM lobby_shared_state
+		WHERE game = $1
+		AND lobby = $2
+	`, game, lobbyCode)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint:errcheck
+
+	state := make(map[string]StateEntry)
+	for rows.Next() {
+		var key string
+		var entry StateEntry
+		if err := rows.Scan(&key, &entry.Value, &entry.Owner, &entry.Access, &entry.Version, &entry.UpdatedAt); err != nil {
+			return nil, err
+		}
+		state[key] = entry
+	}
+	if err = rows.Err(); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
@@ -2,10 +2,18 @@ package stores
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
+	"slices"
 	"time"
 )
 
+// LobbyEventLogSize is the minimum number of events kept in the event log of a lobby.
+const LobbyEventLogSize = 100
+
+// PeerOutboxSize is the number of unacked messages kept in the outbox of a peer.
+const PeerOutboxSize = 100
+
 var ErrAlreadyInLobby = errors.New("peer already in lobby")
 var ErrLobbyExists = errors.New("lobby already exists")
 var ErrNotFound = errors.New("lobby not found")
@@ -13,42 +21,140 @@ var ErrInvalidLobbyCode = errors.New("invalid lobby code")
 var ErrInvalidPeerID = errors.New("invalid peer id")
 var ErrInvalidPassword = errors.New("invalid password")
 var ErrLobbyIsFull = errors.New("lobby is full")
+var ErrPeerNotFound = errors.New("peer not found")
+var ErrInvalidStateTransition = errors.New("invalid lobby state transition")
+var ErrVersionConflict = errors.New("lobby was updated by someone else")
+var ErrStateNotAllowed = errors.New("not allowed to change this state key")
+var ErrNotAllowed = errors.New("not allowed to update this lobby")
+var ErrTooManyStateKeys = errors.New("too many state keys")
+var ErrInvalidTransferToken = errors.New("invalid or expired transfer token")
+var ErrTooManyPeers = errors.New("too many peers connected to this game")
+var ErrTooManyLobbies = errors.New("too many lobbies for this game")
 
 type SubscriptionCallback func(context.Context, []byte)
 
+// SessionTopic is the topic for messages about the session of a peer, like another connection taking it over.
+func SessionTopic(game, peerID string) string {
+	return "session" + game + peerID
+}
+
 type LobbyOptions struct {
 	Public      *bool
 	CustomData  *map[string]any
 	CanUpdateBy *string
 	Password    *string
 	MaxPlayers  *int
+	State       *string
+	ExpiresAt   *time.Time
+	IdleTimeout *time.Duration
+
+	// ReconnectPolicy is what happens to the slot of a peer that lost its connection, see ReconnectPolicyHold.
+	ReconnectPolicy *string
+
+	// CustomDataPatch is applied to the customData as a JSON merge patch (RFC 7396).
+	CustomDataPatch map[string]any
+	// SetCustomData sets these top-level keys of the customData.
+	SetCustomData map[string]any
+	// UnsetCustomData removes these top-level keys from the customData.
+	UnsetCustomData []string
+
+	// ExpectedVersion and ExpectedUpdatedAt make UpdateLobby fail with ErrVersionConflict
+	// when the lobby was updated in the meantime.
+	ExpectedVersion   *int
+	ExpectedUpdatedAt *time.Time
+
+	// ValidateCustomData is called with the resulting customData before it's committed, errors are returned as is.
+	ValidateCustomData func(customData map[string]any) error
+
+	// MaxLobbies makes CreateLobby fail with ErrTooManyLobbies when the game already has
+	// this many non-empty lobbies, 0 means unlimited.
+	MaxLobbies int
 }
 
 type Store interface {
 	CreateLobby(ctx context.Context, Game, LobbyCode, PeerID string, options LobbyOptions) error
 	JoinLobby(ctx context.Context, game, lobby, id, password string) ([]string, error)
-	LeaveLobby(ctx context.Context, game, lobby, id string) error
+	// LeaveLobby removes a peer from a lobby, it returns whether the peer was ready.
+	LeaveLobby(ctx context.Context, game, lobby, id string) (bool, error)
 	GetLobby(ctx context.Context, game, lobby string) (Lobby, error)
-	ListLobbies(ctx context.Context, game string, country, region string, filter, sort string, limit int) ([]Lobby, error)
+	ListLobbies(ctx context.Context, game string, country, region string, filter, sort string, limit int, includeInGame bool) ([]Lobby, error)
 
 	Subscribe(ctx context.Context, callback SubscriptionCallback, game, lobby, peerID string)
 	Publish(ctx context.Context, topic string, data []byte) error
+	// SubscribeSession subscribes to the SessionTopic of a peer.
+	SubscribeSession(ctx context.Context, callback SubscriptionCallback, game, peerID string)
+
+	// PublishLobbyEvent publishes data to the lobby topic and adds it to the event log of the lobby
+	// when it's enabled. A seq field with the sequence number of the event is added to data.
+	PublishLobbyEvent(ctx context.Context, game, lobby string, data []byte) (int64, error)
+	// EnableLobbyEventLog makes PublishLobbyEvent log the events of a lobby from now on.
+	EnableLobbyEventLog(ctx context.Context, game, lobby string) error
+	// GetLobbyEvents returns the logged events after sequence number since and the current sequence number
+	// of the lobby. complete is false when older events were already removed from the log.
+	GetLobbyEvents(ctx context.Context, game, lobby string, since int64) (events []json.RawMessage, seq int64, complete bool, err error)
+
+	// PublishToPeer publishes data to a peer in a lobby and adds it to the outbox of the peer until it's acked.
+	// Peers that don't ack only get data published to them.
+	// A msgSeq field with the sequence number of the message is added to data.
+	PublishToPeer(ctx context.Context, game, lobby, peerID string, data []byte) (int64, error)
+	// AckPeerMessages removes the messages up to and including seq from the outbox of a peer.
+	AckPeerMessages(ctx context.Context, peerID string, seq int64) error
+	// GetPeerMessages returns the messages in the outbox of a peer that weren't acked yet.
+	GetPeerMessages(ctx context.Context, peerID string) ([]json.RawMessage, error)
 
-	CreatePeer(ctx context.Context, peerID, secret, gameID string) error
+	// CreatePeer creates a peer that times out when it isn't seen for disconnectThreshold,
+	// 0 uses the threshold passed to ClaimNextTimedOutPeer. When maxPeers is more than 0 it
+	// fails with ErrTooManyPeers when the game already has that many connected peers.
+	CreatePeer(ctx context.Context, peerID, secret, gameID string, disconnectThreshold time.Duration, maxPeers int) error
 	UpdatePeerGeo(ctx context.Context, peerID string, country, region string) error
+	// UpdatePeerAcks sets if the peer acks its messages, only then PublishToPeer keeps them in its outbox.
+	UpdatePeerAcks(ctx context.Context, peerID string, acks bool) error
+	UpdatePeerIdentity(ctx context.Context, peerID string, userID, displayName string) error
+	UpdatePeerCustomData(ctx context.Context, peerID string, customData map[string]any) error
+	GetPeerInfo(ctx context.Context, gameID, peerID string) (PeerInfo, error)
+	// CreateTransferToken stores a one-time token that can be used to take over a peer until expiresAt.
+	CreateTransferToken(ctx context.Context, peerID, token string, expiresAt time.Time) error
+	// ClaimTransferToken uses up a transfer token for a peer of game and replaces the secret of the peer
+	// with secret, so the old session can't reconnect anymore. It returns the id of the peer.
+	ClaimTransferToken(ctx context.Context, game, token, secret string) (string, error)
 	MarkPeerAsActive(ctx context.Context, peerID string) error
-	MarkPeerAsDisconnected(ctx context.Context, peerID string) error
+	// MarkPeerAsDisconnected marks a peer as disconnected and removes it from the lobbies with the
+	// free reconnect policy. It returns the codes of the lobbies the peer was removed from, and of
+	// those the ones the peer was ready in.
+	MarkPeerAsDisconnected(ctx context.Context, peerID string) (freed []string, wasReady []string, err error)
 	MarkPeerAsReconnected(ctx context.Context, peerID, secret, gameID string) (bool, []string, error)
-	ClaimNextTimedOutPeer(ctx context.Context, threshold time.Duration) (string, bool, map[string][]string, error)
+	// ClaimNextTimedOutPeer deletes a peer that timed out and removes it from its lobbies. It returns the
+	// lobbies per game the peer was removed from, and the codes of the ones the peer was ready in.
+	ClaimNextTimedOutPeer(ctx context.Context, threshold time.Duration) (peerID string, disconnected bool, gameLobbies map[string][]string, wasReady []string, err error)
 	ResetAllPeerLastSeen(ctx context.Context) error
 
-	CleanEmptyLobbies(ctx context.Context, olderThan time.Time) error
+	// CleanEmptyLobbies deletes the lobbies that have been empty since olderThan. When game is set only
+	// lobbies of that game are deleted, lobbies of games in skipGames are never deleted.
+	CleanEmptyLobbies(ctx context.Context, olderThan time.Time, game string, skipGames []string) error
+
+	// ClaimExpiredLobbies deletes and returns lobbies that passed their expiresAt or idle timeout.
+	ClaimExpiredLobbies(ctx context.Context, limit int) ([]ExpiredLobby, error)
+
+	// UpsertPersistentLobby creates a persistent lobby or updates the settings of an existing persistent lobby.
+	// It fails with ErrLobbyExists when a lobby that isn't persistent has the code.
+	UpsertPersistentLobby(ctx context.Context, game, lobbyCode string, options LobbyOptions) error
+	// UnsetPersistentLobby makes a lobby non-persistent again, it's cleaned up as usual once it's empty.
+	UnsetPersistentLobby(ctx context.Context, game, lobbyCode string) error
 
 	// DoLeaderElection attempts to elect a leader for the given lobby. If a correct leader already exists it will return nil.
 	// If no leader can be elected, it will return an ElectionResult with a nil leader.
 	DoLeaderElection(ctx context.Context, gameID, lobbyCode string) (*ElectionResult, error)
 
 	UpdateLobby(ctx context.Context, Game, LobbyCode, PeerID string, options LobbyOptions) error
+
+	// SetPeerReady sets the ready flag of a peer in a lobby. It returns the peers that are ready
+	// and whether all peers in the lobby are ready now.
+	SetPeerReady(ctx context.Context, game, lobby, peerID string, ready bool) ([]string, bool, error)
+
+	// SetLobbyState sets a key of the shared state of a lobby, a null value removes the key.
+	SetLobbyState(ctx context.Context, game, lobby, peerID, key string, update StateUpdate) (StateEntry, error)
+	// GetLobbyState returns the shared state of a lobby.
+	GetLobbyState(ctx context.Context, game, lobby string) (map[string]StateEntry, error)
 }
 
 const (
@@ -58,6 +164,46 @@ const (
 	CanUpdateByNone    = "none"
 )
 
+const (
+	LobbyStateWaiting  = "waiting"
+	LobbyStateStarting = "starting"
+	LobbyStateInGame   = "in-game"
+	LobbyStateFinished = "finished"
+)
+
+const (
+	// ReconnectPolicyHold keeps peers that lost their connection in the lobby until they time out,
+	// so they can reconnect and continue where they left off.
+	ReconnectPolicyHold = "hold"
+	// ReconnectPolicyFree removes peers from the lobby as soon as they lose their connection.
+	ReconnectPolicyFree = "free"
+)
+
+// IsValidReconnectPolicy returns whether policy is one of the known reconnect policies.
+func IsValidReconnectPolicy(policy string) bool {
+	return policy == ReconnectPolicyHold || policy == ReconnectPolicyFree
+}
+
+// lobbyStateTransitions lists the states a lobby can move to from each state.
+var lobbyStateTransitions = map[string][]string{
+	LobbyStateWaiting:  {LobbyStateStarting, LobbyStateInGame},
+	LobbyStateStarting: {LobbyStateWaiting, LobbyStateInGame},
+	LobbyStateInGame:   {LobbyStateFinished, LobbyStateWaiting},
+	LobbyStateFinished: {LobbyStateWaiting},
+}
+
+// IsValidLobbyState returns whether state is one of the known lobby states.
+func IsValidLobbyState(state string) bool {
+	_, ok := lobbyStateTransitions[state]
+	return ok
+}
+
+// CanTransitionLobbyState returns whether a lobby can move from one state to another.
+// Staying in the same state is always allowed.
+func CanTransitionLobbyState(from, to string) bool {
+	return from == to || slices.Contains(lobbyStateTransitions[from], to)
+}
+
 type Lobby struct {
 	Code        string   `json:"code"`
 	Peers       []string `json:"peers,omitempty"`
@@ -70,15 +216,94 @@ type Lobby struct {
 	CustomData  map[string]any `json:"customData"`
 	CanUpdateBy string         `json:"canUpdateBy"`
 
+	State string   `json:"state"`
+	Ready []string `json:"ready,omitempty"`
+
+	Persistent bool `json:"persistent,omitempty"`
+
+	// Version is incremented on every update of the lobby settings or customData.
+	Version int `json:"version"`
+
+	// Seq is the sequence number of the last event published to the lobby.
+	Seq int64 `json:"seq,omitempty"`
+
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	IdleTimeout int        `json:"idleTimeout,omitempty"` // in seconds
+
+	ReconnectPolicy string `json:"reconnectPolicy"`
+
 	Leader string `json:"leader,omitempty"`
 	Term   int    `json:"term"`
 
+	PeerInfo map[string]PeerInfo `json:"peerInfo,omitempty"`
+
 	Latency *float32 `json:"latency,omitempty"`
 
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt"`
 }
 
+// PeerInfo is the public information about a peer shared with other peers in the same lobby.
+type PeerInfo struct {
+	ID          string `json:"id"`
+	UserID      string `json:"userId,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+
+	CustomData map[string]any `json:"customData,omitempty"`
+}
+
+const (
+	ExpiredReasonTTL  = "expired"
+	ExpiredReasonIdle = "idle"
+)
+
+// ExpiredLobby is a lobby that was deleted because it expired.
+type ExpiredLobby struct {
+	Game   string
+	Code   string
+	Peers  []string
+	Reason string
+}
+
+const (
+	StateAccessAnyone = "anyone"
+	StateAccessLeader = "leader"
+	StateAccessOwner  = "owner"
+)
+
+// IsValidStateAccess returns whether access is a valid access rule for shared state keys.
+func IsValidStateAccess(access string) bool {
+	return access == StateAccessAnyone || access == StateAccessLeader || access == StateAccessOwner
+}
+
+// StateEntry is a single key of the shared state of a lobby.
+type StateEntry struct {
+	Value     json.RawMessage `json:"value"`
+	Owner     string          `json:"owner"`
+	Access    string          `json:"access"`
+	Version   int             `json:"version"`
+	UpdatedAt time.Time       `json:"updatedAt"`
+}
+
+// StateUpdate describes a change to a key of the shared state of a lobby.
+type StateUpdate struct {
+	// Value is the new value, null removes the key.
+	Value json.RawMessage
+	// Access changes who can set the key, only the owner can change it. Empty keeps the current access,
+	// new keys default to StateAccessAnyone.
+	Access string
+	// ExpectedVersion makes the update fail with ErrVersionConflict when the key has another version,
+	// use 0 for keys that shouldn't exist yet.
+	ExpectedVersion *int
+	// MaxKeys is the maximum number of keys a lobby can have, 0 is unlimited.
+	MaxKeys int
+}
+
+// Deleted returns whether the update removes the key.
+func (u StateUpdate) Deleted() bool {
+	return len(u.Value) == 0 || string(u.Value) == "null"
+}
+
 type ElectionResult struct {
 	Leader string
 	Term   int
@@ -4,15 +4,18 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"slices"
 	"time"
 
 	"github.com/koenbollen/logging"
+	"github.com/poki/netlib/internal/gameconfig"
 	"github.com/poki/netlib/internal/metrics"
 	"github.com/poki/netlib/internal/signaling/stores"
 	"go.uber.org/zap"
 )
 
 type TimeoutManager struct {
+	// DisconnectThreshold is used for peers that were created without a threshold of their own.
 	DisconnectThreshold time.Duration
 
 	Store stores.Store
@@ -26,9 +29,7 @@ func (manager *TimeoutManager) Run(ctx context.Context) {
 	}
 
 	if manager.DisconnectThreshold == 0 {
-		// We update peer activity every 30 seconds. Make it possible to somehow miss
-		// two updates before we consider a peer timed out.
-		manager.DisconnectThreshold = time.Second * 90
+		manager.DisconnectThreshold = gameconfig.DefaultDisconnectThreshold
 	}
 
 	for ctx.Err() == nil {
@@ -41,7 +42,7 @@ func (manager *TimeoutManager) RunOnce(ctx context.Context) {
 	logger := logging.GetLogger(ctx)
 
 	for ctx.Err() == nil {
-		peerID, disconnected, gameLobbies, err := manager.Store.ClaimNextTimedOutPeer(ctx, manager.DisconnectThreshold)
+		peerID, disconnected, gameLobbies, wasReady, err := manager.Store.ClaimNextTimedOutPeer(ctx, manager.DisconnectThreshold)
 		if err != nil {
 			logger.Error("failed to claim next timedout peer", zap.Error(err))
 		}
@@ -56,6 +57,9 @@ func (manager *TimeoutManager) RunOnce(ctx context.Context) {
 				if err := manager.disconnectPeerInLobby(ctx, peerID, gameID, lobbyCode); err != nil {
 					logger.Error("failed to disconnect peer", zap.Error(err), zap.String("peer", peerID), zap.String("game", gameID), zap.String("lobby", lobbyCode))
 				}
+				if err := publishReadyAfterRemoval(ctx, manager.Store, gameID, lobbyCode, peerID, slices.Contains(wasReady, lobbyCode)); err != nil {
+					logger.Error("failed to publish ready flags", zap.Error(err), zap.String("peer", peerID), zap.String("game", gameID), zap.String("lobby", lobbyCode))
+				}
 
 				// If the peer wasn't disconnected normally, they might still be the leader of a lobby.
 				// Just to be sure, do a leader election.
@@ -84,7 +88,7 @@ func (manager *TimeoutManager) disconnectPeerInLobby(ctx context.Context, peerID
 		return err
 	}
 
-	err = manager.Store.Publish(ctx, gameID+lobby, data)
+	_, err = manager.Store.PublishLobbyEvent(ctx, gameID, lobby, data)
 	if err != nil {
 		return err
 	}
@@ -99,22 +103,63 @@ func (manager *TimeoutManager) Disconnected(ctx context.Context, p *Peer) {
 	}
 
 	logger.Debug("peer marked as disconnected", zap.String("id", p.ID), zap.String("lobby", p.Lobby))
-	err := manager.Store.MarkPeerAsDisconnected(ctx, p.ID)
+	freed, wasReady, err := manager.Store.MarkPeerAsDisconnected(ctx, p.ID)
 	if err != nil {
 		logger.Error("failed to record timeout peer", zap.Error(err))
-	} else {
-		err := manager.doLeaderElectionAndPublish(ctx, p.Game, p.Lobby)
+		return
+	}
+
+	if p.Lobby != "" {
+		if slices.Contains(freed, p.Lobby) {
+			// The lobby doesn't hold slots for reconnecting peers, so the peer is gone for good.
+			err = manager.disconnectPeerInLobby(ctx, p.ID, p.Game, p.Lobby)
+			if err == nil {
+				err = publishReadyAfterRemoval(ctx, manager.Store, p.Game, p.Lobby, p.ID, slices.Contains(wasReady, p.Lobby))
+			}
+		} else {
+			err = manager.publishPeerStatus(ctx, p.ID, p.Game, p.Lobby, PeerStatusReconnecting)
+		}
 		if err != nil {
-			logger.Error("failed to do leader election", zap.Error(err), zap.String("game", p.Game), zap.String("lobby", p.Lobby))
+			logger.Error("failed to publish disconnect", zap.Error(err), zap.String("game", p.Game), zap.String("lobby", p.Lobby))
 		}
 	}
+
+	err = manager.doLeaderElectionAndPublish(ctx, p.Game, p.Lobby)
+	if err != nil {
+		logger.Error("failed to do leader election", zap.Error(err), zap.String("game", p.Game), zap.String("lobby", p.Lobby))
+	}
+}
+
+func (manager *TimeoutManager) publishPeerStatus(ctx context.Context, peerID string, gameID string, lobby string, status string) error {
+	packet := PeerStatusPacket{
+		Type:   "peerStatus",
+		ID:     peerID,
+		Status: status,
+	}
+	data, err := json.Marshal(packet)
+	if err != nil {
+		return err
+	}
+
+	_, err = manager.Store.PublishLobbyEvent(ctx, gameID, lobby, data)
+	return err
 }
 
 func (manager *TimeoutManager) Reconnected(ctx context.Context, peerID, secret, gameID string) (bool, []string, error) {
 	logger := logging.GetLogger(ctx)
 
 	logger.Debug("peer marked as reconnected", zap.String("peer", peerID))
-	return manager.Store.MarkPeerAsReconnected(ctx, peerID, secret, gameID)
+	reconnected, lobbies, err := manager.Store.MarkPeerAsReconnected(ctx, peerID, secret, gameID)
+	if err != nil || !reconnected {
+		return reconnected, lobbies, err
+	}
+
+	for _, lobby := range lobbies {
+		if err := manager.publishPeerStatus(ctx, peerID, gameID, lobby, PeerStatusOnline); err != nil {
+			logger.Error("failed to publish peer status", zap.Error(err), zap.String("peer", peerID), zap.String("game", gameID), zap.String("lobby", lobby))
+		}
+	}
+	return true, lobbies, nil
 }
 
 func (manager *TimeoutManager) MarkPeerAsActive(ctx context.Context, peerID string) {
@@ -149,7 +194,7 @@ func (manager *TimeoutManager) doLeaderElectionAndPublish(ctx context.Context, g
 		return err
 	}
 
-	err = manager.Store.Publish(ctx, gameID+lobbyCode, data)
+	_, err = manager.Store.PublishLobbyEvent(ctx, gameID, lobbyCode, data)
 	if err != nil {
 		return err
 	}
@@ -1,24 +1,35 @@
 package signaling
 
+// The packets clients send are generated from schema/packets.json into packets_gen.go.
+//go:generate go run ../../cmd/packetgen -go packets_gen.go -ts ../../lib/packets.ts
+
 import (
-	"encoding/json"
+	"time"
 
 	"github.com/poki/netlib/internal/cloudflare"
 	"github.com/poki/netlib/internal/metrics"
 	"github.com/poki/netlib/internal/signaling/stores"
 )
 
-type PingPacket struct {
-	Type string `json:"type"`
+type TransferTokenPacket struct {
+	RequestID string `json:"rid"`
+	Type      string `json:"type"`
+
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
 }
 
-type HelloPacket struct {
+// SessionTakeoverPacket is published to the session topic of a peer when another connection
+// took over the peer. It's never sent to clients, connections other than Conn close instead.
+type SessionTakeoverPacket struct {
 	Type string `json:"type"`
 
-	Game    string `json:"game"`
-	ID      string `json:"id"`
-	Secret  string `json:"secret"`
-	Version string `json:"version"`
+	Conn   string `json:"conn"`
+	Reason string `json:"reason"`
+
+	// StartedAt is when the new connection said hello. Connections that said hello later
+	// ignore the message, so when two connections say hello at once the newest one wins.
+	StartedAt time.Time `json:"startedAt"`
 }
 
 type WelcomePacket struct {
@@ -26,50 +37,67 @@ type WelcomePacket struct {
 
 	ID     string `json:"id"`
 	Secret string `json:"secret"`
+
+	UserID      string `json:"userId,omitempty"`
+	DisplayName string `json:"displayName,omitempty"`
+
+	// ProtocolVersion and Capabilities tell the client what this server supports.
+	ProtocolVersion int      `json:"protocolVersion"`
+	Capabilities    []string `json:"capabilities"`
 }
 
-type ListPacket struct {
+type LobbiesPacket struct {
 	RequestID string `json:"rid"`
 	Type      string `json:"type"`
 
-	Filter string `json:"filter"`
-	Sort   string `json:"sort"`
-	Limit  int    `json:"limit"`
+	Lobbies []stores.Lobby `json:"lobbies"`
 }
 
-type LobbiesPacket struct {
+type JoinedPacket struct {
 	RequestID string `json:"rid"`
 	Type      string `json:"type"`
 
-	Lobbies []stores.Lobby `json:"lobbies"`
+	LobbyCode string       `json:"lobby,omitempty"` // for clients without the lobbyInfo capability, can't rename
+	LobbyInfo stores.Lobby `json:"lobbyInfo"`
+
+	SharedState map[string]stores.StateEntry `json:"sharedState,omitempty"`
 }
 
-type CreatePacket struct {
+type LobbyClosedPacket struct {
+	Type string `json:"type"`
+
+	Lobby  string `json:"lobby"`
+	Reason string `json:"reason"`
+}
+
+type StatePacket struct {
 	RequestID string `json:"rid"`
 	Type      string `json:"type"`
 
-	CodeFormat  string         `json:"codeFormat"`
-	Public      bool           `json:"public"`
-	Password    string         `json:"password"`
-	MaxPlayers  *int           `json:"maxPlayers"`
-	CustomData  map[string]any `json:"customData"`
-	CanUpdateBy string         `json:"canUpdateBy"`
+	State map[string]stores.StateEntry `json:"state"`
 }
 
-type JoinPacket struct {
+type StateUpdatedPacket struct {
 	RequestID string `json:"rid"`
 	Type      string `json:"type"`
 
-	Lobby    string `json:"lobby"`
-	Password string `json:"password"`
+	Key       string `json:"key"`
+	UpdatedBy string `json:"updatedBy"`
+	Deleted   bool   `json:"deleted,omitempty"`
+	stores.StateEntry
 }
 
-type JoinedPacket struct {
+type ReplayedPacket struct {
 	RequestID string `json:"rid"`
 	Type      string `json:"type"`
 
-	LobbyCode string       `json:"lobby"` // for backwards compatibility, can't rename
-	LobbyInfo stores.Lobby `json:"lobbyInfo"`
+	Lobby string `json:"lobby"`
+	Seq   int64  `json:"seq"`
+
+	// Complete is false when some events weren't in the event log anymore, LobbyInfo
+	// then contains the current state of the lobby.
+	Complete  bool          `json:"complete"`
+	LobbyInfo *stores.Lobby `json:"lobbyInfo,omitempty"`
 }
 
 type LeaderPacket struct {
@@ -79,27 +107,34 @@ type LeaderPacket struct {
 	Term   int    `json:"term"`
 }
 
-type LobbyUpdatePacket struct {
+type LobbyUpdatedPacket struct {
 	RequestID string `json:"rid"`
 	Type      string `json:"type"`
 
-	Public      *bool           `json:"public"`
-	CustomData  *map[string]any `json:"customData"`
-	CanUpdateBy *string         `json:"canUpdateBy"`
-	Password    *string         `json:"password"`
-	MaxPlayers  *int            `json:"maxPlayers"`
+	LobbyInfo stores.Lobby `json:"lobbyInfo"`
 }
 
-type LobbyUpdatedPacket struct {
+type PeerUpdatedPacket struct {
 	RequestID string `json:"rid"`
 	Type      string `json:"type"`
 
-	LobbyInfo stores.Lobby `json:"lobbyInfo"`
+	Peer stores.PeerInfo `json:"peer"`
 }
 
-type LeavePacket struct {
+type ReadyUpdatedPacket struct {
 	RequestID string `json:"rid"`
 	Type      string `json:"type"`
+
+	// ID is the peer that changed its ready flag, it's empty when the flags of all peers were reset.
+	ID         string   `json:"id,omitempty"`
+	Ready      bool     `json:"ready"`
+	ReadyPeers []string `json:"readyPeers"`
+}
+
+type AllReadyPacket struct {
+	Type string `json:"type"`
+
+	ReadyPeers []string `json:"readyPeers"`
 }
 
 type LeftPacket struct {
@@ -112,6 +147,8 @@ type ConnectPacket struct {
 
 	ID     string `json:"id"`
 	Polite bool   `json:"polite"`
+
+	Peer *stores.PeerInfo `json:"peer,omitempty"`
 }
 
 type DisconnectPacket struct {
@@ -121,18 +158,18 @@ type DisconnectPacket struct {
 	Reason string `json:"reason"`
 }
 
-type ClosePacket struct {
-	Type string `json:"type"`
-
-	ID     string `json:"id"`
-	Reason string `json:"reason"`
-}
+const (
+	PeerStatusReconnecting = "reconnecting"
+	PeerStatusOnline       = "online"
+)
 
-type ForwardablePacket struct {
+// PeerStatusPacket is published to a lobby when a peer lost its connection (reconnecting) and
+// when it's back (online). When the peer doesn't reconnect in time a disconnect packet follows.
+type PeerStatusPacket struct {
 	Type string `json:"type"`
 
-	Source    string `json:"source"`
-	Recipient string `json:"recipient"`
+	ID     string `json:"id"`
+	Status string `json:"status"`
 }
 
 type CredentialsPacket struct {
@@ -145,27 +182,3 @@ type EventPacket struct {
 	metrics.EventParams
 	Type string `json:"type"`
 }
-
-type MissingRecipientError struct {
-	Recipient string `json:"recipient"`
-	Cause     error  `json:"cause"`
-}
-
-func (e *MissingRecipientError) MarshalJSON() ([]byte, error) {
-	return json.Marshal(map[string]any{
-		"recipient": e.Recipient,
-		"cause":     e.Cause.Error(),
-	})
-}
-
-func (e *MissingRecipientError) Error() string {
-	return "missing recipient: " + e.Recipient
-}
-
-func (e *MissingRecipientError) ErrorCode() string {
-	return "missing-recipient"
-}
-
-func (e *MissingRecipientError) Unwrap() error {
-	return e.Cause
-}
@@ -12,8 +12,8 @@ import (
 	"syscall"
 
 	"github.com/coder/websocket"
-	"github.com/coder/websocket/wsjson"
 	"github.com/koenbollen/logging"
+	"github.com/poki/netlib/internal/wire"
 	"go.uber.org/zap"
 )
 
@@ -40,10 +40,28 @@ func (e *errorCodeError) ErrorCode() string {
 	return e.code
 }
 
+func (e *errorCodeError) Unwrap() error {
+	return e.err
+}
+
 func ErrorWithCode(err error, code string) error {
 	return &errorCodeError{err: err, code: code}
 }
 
+// HasErrorCode returns whether err has an error code, like errors returned by ErrorWithCode.
+func HasErrorCode(err error) bool {
+	return ErrorCode(err) != ""
+}
+
+// ErrorCode returns the code of err, or of the first error it wraps that has one.
+func ErrorCode(err error) string {
+	var cerr interface{ ErrorCode() string }
+	if errors.As(err, &cerr) {
+		return cerr.ErrorCode()
+	}
+	return ""
+}
+
 func ErrorAndAbort(w http.ResponseWriter, r *http.Request, status int, key string, errs ...error) {
 	if status/100 == 5 && len(errs) != 0 {
 		logger := logging.GetLogger(r.Context())
@@ -92,10 +110,8 @@ func ReplyError(ctx context.Context, conn *websocket.Conn, err error) {
 	if rid, ok := ctx.Value(requestIDContextKey).(string); ok {
 		payload.RequestID = rid
 	}
-	if cerr, ok := err.(interface{ ErrorCode() string }); ok {
-		payload.Code = cerr.ErrorCode()
-	}
-	err = wsjson.Write(ctx, conn, &payload)
+	payload.Code = ErrorCode(err)
+	err = wire.Write(ctx, conn, &payload)
 	if err != nil && !ShouldIgnoreNetworkError(err) {
 		logger := logging.GetLogger(ctx)
 		logger.Warn("uncaught server error", zap.Error(err), zap.Stack("stack"))
@@ -152,6 +168,15 @@ func ShouldIgnoreNetworkError(err error) bool {
 	return false
 }
 
+// RemoteAddr returns the address of the client that made the request, taking
+// the first address of the X-Forwarded-For header when set by a proxy.
+func RemoteAddr(r *http.Request) string {
+	if r.Header.Get("X-Forwarded-For") != "" {
+		return strings.TrimSpace(strings.Split(r.Header.Get("X-Forwarded-For"), ",")[0])
+	}
+	return r.RemoteAddr
+}
+
 func NoStoreMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodGet {
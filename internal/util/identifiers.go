@@ -32,13 +32,29 @@ func GeneratePeerID(ctx context.Context) string {
 	return xid.New().String()
 }
 
+// GenerateConnectionID generates an ID for a single websocket connection. Unlike
+// peer IDs these don't survive reconnects.
+func GenerateConnectionID(ctx context.Context) string {
+	return xid.New().String()
+}
+
 func GenerateSecret(ctx context.Context) string {
 	if isTestEnv {
 		return "secret" // deterministic for testing
 	}
 
-	buf := make([]byte, 15)
-	if _, err := crand.Read(buf[:]); err != nil {
+	return randomString(ctx, 15)
+}
+
+// GenerateTransferToken generates a one-time token to take over a peer with.
+func GenerateTransferToken(ctx context.Context) string {
+	return randomString(ctx, 20)
+}
+
+// randomString returns n cryptographically random bytes encoded as lowercase base32.
+func randomString(ctx context.Context, n int) string {
+	buf := make([]byte, n)
+	if _, err := crand.Read(buf); err != nil {
 		logger := logging.GetLogger(ctx)
 		logger.Error("error generating secret", zap.Error(err))
 		panic(err)
@@ -69,3 +85,45 @@ func GenerateShortLobbyCode(ctx context.Context, chars int) string {
 	}
 	return strings.Join(ss, "")
 }
+
+// IsValidLobbyCode returns whether code can be used as a custom lobby code. Codes are
+// limited to alphanumeric characters and dashes so they can be used in pubsub topics.
+func IsValidLobbyCode(code string) bool {
+	if len(code) < 2 || len(code) > 20 {
+		return false
+	}
+	for _, r := range code {
+		if (r < '0' || r > '9') && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && r != '-' {
+			return false
+		}
+	}
+	return code[0] != '-' && code[len(code)-1] != '-'
+}
+
+// GenerateNumericLobbyCode generates a lobby code of the given number of digits, like a PIN.
+func GenerateNumericLobbyCode(ctx context.Context, digits int) string {
+	randIntn := rand.Intn
+	if isTestEnv {
+		randIntn = deterministicRand.Intn
+	}
+
+	b := make([]byte, digits)
+	for i := range b {
+		b[i] = byte('0' + randIntn(10))
+	}
+	return string(b)
+}
+
+// GenerateWordLobbyCode generates a lobby code like "brave-otter-42" that ends with the given number of digits.
+func GenerateWordLobbyCode(ctx context.Context, digits int) string {
+	randIntn := rand.Intn
+	if isTestEnv {
+		randIntn = deterministicRand.Intn
+	}
+
+	code := adjectives[randIntn(len(adjectives))] + "-" + nouns[randIntn(len(nouns))]
+	if digits > 0 {
+		code += "-" + GenerateNumericLobbyCode(ctx, digits)
+	}
+	return code
+}
@@ -0,0 +1,84 @@
+package util
+
+import (
+	"sync"
+	"time"
+)
+
+// RateLimit describes a token bucket that holds at most Burst tokens and is
+// refilled with Rate tokens per second.
+type RateLimit struct {
+	Rate  float64
+	Burst int
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// RateLimiter keeps a token bucket per key. It is safe for concurrent use.
+type RateLimiter struct {
+	mutex   sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+func NewRateLimiter() *RateLimiter {
+	return &RateLimiter{
+		buckets: make(map[string]*tokenBucket),
+	}
+}
+
+// Allow takes a token from the bucket of key and reports whether one was available.
+// A limit without a burst is treated as unlimited.
+func (l *RateLimiter) Allow(key string, limit RateLimit, now time.Time) bool {
+	if limit.Burst <= 0 {
+		return true
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	b, found := l.buckets[key]
+	if !found {
+		b = &tokenBucket{tokens: float64(limit.Burst), last: now}
+		l.buckets[key] = b
+	} else if now.After(b.last) {
+		b.tokens = min(float64(limit.Burst), b.tokens+now.Sub(b.last).Seconds()*limit.Rate)
+		b.last = now
+	}
+
+	if b.tokens < 1 {
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// Refund puts back a token taken by Allow, for when the request was rejected
+// for another reason. The bucket never holds more than Burst tokens.
+func (l *RateLimiter) Refund(key string, limit RateLimit) {
+	if limit.Burst <= 0 {
+		return
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if b, found := l.buckets[key]; found {
+		b.tokens = min(float64(limit.Burst), b.tokens+1)
+	}
+}
+
+// Prune removes the buckets that haven't been used since before. Callers should
+// only prune buckets that would have been refilled completely by now.
+func (l *RateLimiter) Prune(before time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key, b := range l.buckets {
+		if b.last.Before(before) {
+			delete(l.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package util_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/poki/netlib/internal/util"
+)
+
+func TestRateLimiter(t *testing.T) {
+	limiter := util.NewRateLimiter()
+	limit := util.RateLimit{Rate: 2, Burst: 3}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("a", limit, now) {
+			t.Fatalf("expected burst token %d to be allowed", i)
+		}
+	}
+	if limiter.Allow("a", limit, now) {
+		t.Fatal("expected bucket to be empty after the burst")
+	}
+	if !limiter.Allow("b", limit, now) {
+		t.Fatal("expected other keys to have their own bucket")
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if !limiter.Allow("a", limit, now) {
+		t.Fatal("expected a token to be refilled after 500ms")
+	}
+	if limiter.Allow("a", limit, now) {
+		t.Fatal("expected only one token to be refilled after 500ms")
+	}
+
+	now = now.Add(time.Hour)
+	for i := 0; i < 3; i++ {
+		if !limiter.Allow("a", limit, now) {
+			t.Fatalf("expected refilled token %d to be allowed", i)
+		}
+	}
+	if limiter.Allow("a", limit, now) {
+		t.Fatal("expected refill to be capped at the burst")
+	}
+
+	if !limiter.Allow("a", util.RateLimit{}, now) {
+		t.Fatal("expected a zero limit to be unlimited")
+	}
+
+	limiter.Prune(now.Add(time.Second))
+	if !limiter.Allow("a", limit, now) {
+		t.Fatal("expected pruned bucket to start full")
+	}
+}
+
+func TestRateLimiterRefund(t *testing.T) {
+	limiter := util.NewRateLimiter()
+	limit := util.RateLimit{Rate: 1, Burst: 1}
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	if !limiter.Allow("a", limit, now) {
+		t.Fatal("expected the first token to be allowed")
+	}
+	limiter.Refund("a", limit)
+	if !limiter.Allow("a", limit, now) {
+		t.Fatal("expected the refunded token to be allowed")
+	}
+	if limiter.Allow("a", limit, now) {
+		t.Fatal("expected bucket to be empty after using the refunded token")
+	}
+
+	limiter.Refund("a", limit)
+	limiter.Refund("a", limit)
+	if !limiter.Allow("a", limit, now) || limiter.Allow("a", limit, now) {
+		t.Fatal("expected refunds to be capped at the burst")
+	}
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Version is a major.minor.patch version number, like the version client libraries send in hello.
+type Version [3]int
+
+// ParseVersion parses versions like 1.2.3, v1.2 or 1.2.3-beta.1. Pre-release and build
+// suffixes are ignored, missing minor and patch numbers are 0.
+func ParseVersion(s string) (Version, bool) {
+	var v Version
+	s = strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if s == "" || len(parts) > 3 {
+		return v, false
+	}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
+
+// Less returns whether v is an older version than other.
+func (v Version) Less(other Version) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+	return false
+}
+
+func (v Version) String() string {
+	return strconv.Itoa(v[0]) + "." + strconv.Itoa(v[1]) + "." + strconv.Itoa(v[2])
+}
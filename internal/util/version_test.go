@@ -0,0 +1,42 @@
+package util_test
+
+import (
+	"testing"
+
+	"github.com/poki/netlib/internal/util"
+)
+
+func Test_ParseVersion(t *testing.T) {
+	tests := []struct {
+		in   string
+		want util.Version
+		ok   bool
+	}{
+		{"1.2.3", util.Version{1, 2, 3}, true},
+		{"v0.0.20", util.Version{0, 0, 20}, true},
+		{"1.2", util.Version{1, 2, 0}, true},
+		{"2", util.Version{2, 0, 0}, true},
+		{"1.2.3-beta.1", util.Version{1, 2, 3}, true},
+		{"1.2.3+build", util.Version{1, 2, 3}, true},
+		{"", util.Version{}, false},
+		{"1.2.3.4", util.Version{}, false},
+		{"1.x", util.Version{}, false},
+		{"-1.0", util.Version{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, ok := util.ParseVersion(tt.in)
+			if ok != tt.ok || (ok && got != tt.want) {
+				t.Errorf("ParseVersion(%q) = %v, %v, want %v, %v", tt.in, got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func Test_VersionLess(t *testing.T) {
+	a, _ := util.ParseVersion("0.0.20")
+	b, _ := util.ParseVersion("0.1.0")
+	if !a.Less(b) || b.Less(a) || a.Less(a) {
+		t.Errorf("expected %s < %s", a, b)
+	}
+}
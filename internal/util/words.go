@@ -0,0 +1,25 @@
+package util
+
+// adjectives and nouns are used for word lobby codes like "brave-otter-42". All
+// words are at most 7 characters so the codes fit in the 20 characters of a lobby code.
+var adjectives = []string{
+	"able", "amber", "bold", "brave", "brisk", "calm", "clever", "cosmic",
+	"cozy", "crisp", "curious", "daring", "eager", "early", "fancy", "fast",
+	"fierce", "fluffy", "gentle", "giant", "glad", "golden", "grand", "happy",
+	"hidden", "humble", "icy", "jolly", "kind", "lively", "loud", "lucky",
+	"magic", "mellow", "merry", "mighty", "misty", "noble", "plucky", "polite",
+	"proud", "quick", "quiet", "rapid", "rusty", "shiny", "silent", "silver",
+	"sleepy", "smart", "snowy", "sneaky", "solar", "speedy", "spicy", "steady",
+	"sunny", "swift", "tidy", "tiny", "vivid", "wild", "wise", "witty",
+}
+
+var nouns = []string{
+	"badger", "bear", "beaver", "bison", "camel", "cobra", "condor", "coral",
+	"coyote", "crane", "dingo", "dragon", "eagle", "falcon", "ferret", "finch",
+	"fox", "gecko", "goose", "heron", "hippo", "husky", "ibis", "iguana",
+	"jackal", "koala", "lemur", "lion", "llama", "lynx", "magpie", "marmot",
+	"moose", "narwhal", "newt", "ocelot", "okapi", "orca", "osprey", "otter",
+	"owl", "panda", "parrot", "pelican", "penguin", "puffin", "python", "quokka",
+	"raven", "rhino", "robin", "salmon", "seal", "shark", "sloth", "sparrow",
+	"squid", "stork", "tapir", "tiger", "toucan", "turtle", "walrus", "wombat",
+}
@@ -0,0 +1,137 @@
+// Package wire implements the encodings packets can be sent in over the signaling websocket.
+//
+// Packets are JSON by default. Clients can negotiate MessagePack with the msgpack websocket
+// subprotocol, all packets are then sent as binary MessagePack messages. Binary messages from
+// clients are always decoded as MessagePack, no matter which subprotocol was negotiated.
+// Packets from clients are decoded straight into their packet types, see Message.
+package wire
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/coder/websocket"
+)
+
+const (
+	SubprotocolJSON    = "json"
+	SubprotocolMsgpack = "msgpack"
+)
+
+// Subprotocols are the websocket subprotocols the server supports, in order of preference.
+var Subprotocols = []string{SubprotocolMsgpack, SubprotocolJSON}
+
+// Codec encodes packets sent to a client and decodes the packets it sends.
+type Codec interface {
+	// Marshal encodes a packet.
+	Marshal(v any) ([]byte, error)
+	// Unmarshal decodes a packet into v.
+	Unmarshal(data []byte, v any) error
+	// FromJSON converts a packet that is already encoded as JSON, like the packets published to other peers.
+	FromJSON(data []byte) ([]byte, error)
+	// ToJSON converts a packet to JSON, to publish it to other peers.
+	ToJSON(data []byte) ([]byte, error)
+	// MessageType is the websocket message type packets are sent in.
+	MessageType() websocket.MessageType
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Marshal(v any) ([]byte, error)        { return json.Marshal(v) }
+func (jsonCodec) Unmarshal(data []byte, v any) error   { return json.Unmarshal(data, v) }
+func (jsonCodec) FromJSON(data []byte) ([]byte, error) { return data, nil }
+func (jsonCodec) ToJSON(data []byte) ([]byte, error)   { return data, nil }
+func (jsonCodec) MessageType() websocket.MessageType   { return websocket.MessageText }
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error)        { return MarshalMsgpack(v) }
+func (msgpackCodec) Unmarshal(data []byte, v any) error   { return UnmarshalMsgpack(data, v) }
+func (msgpackCodec) FromJSON(data []byte) ([]byte, error) { return JSONToMsgpack(data) }
+func (msgpackCodec) ToJSON(data []byte) ([]byte, error)   { return MsgpackToJSON(data) }
+func (msgpackCodec) MessageType() websocket.MessageType   { return websocket.MessageBinary }
+
+var (
+	JSON    Codec = jsonCodec{}
+	Msgpack Codec = msgpackCodec{}
+)
+
+// ForSubprotocol returns the codec for a negotiated websocket subprotocol, JSON when none was negotiated.
+func ForSubprotocol(subprotocol string) Codec {
+	if subprotocol == SubprotocolMsgpack {
+		return Msgpack
+	}
+	return JSON
+}
+
+type codecContextKey struct{}
+
+// WithCodec returns a context that writes packets using codec, see Write.
+func WithCodec(ctx context.Context, codec Codec) context.Context {
+	return context.WithValue(ctx, codecContextKey{}, codec)
+}
+
+// CodecFromContext returns the codec set with WithCodec, or JSON.
+func CodecFromContext(ctx context.Context) Codec {
+	if codec, ok := ctx.Value(codecContextKey{}).(Codec); ok {
+		return codec
+	}
+	return JSON
+}
+
+// Write encodes v with the codec of ctx and writes it to conn.
+func Write(ctx context.Context, conn *websocket.Conn, v any) error {
+	codec := CodecFromContext(ctx)
+	data, err := codec.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return write(ctx, conn, codec, data)
+}
+
+// WriteJSON converts a packet that's already encoded as JSON with the codec of ctx and writes it to conn.
+func WriteJSON(ctx context.Context, conn *websocket.Conn, data []byte) error {
+	codec := CodecFromContext(ctx)
+	data, err := codec.FromJSON(data)
+	if err != nil {
+		return err
+	}
+	return write(ctx, conn, codec, data)
+}
+
+func write(ctx context.Context, conn *websocket.Conn, codec Codec, data []byte) error {
+	start := time.Now()
+	err := conn.Write(ctx, codec.MessageType(), data)
+	statsFromContext(ctx).recordWrite(len(data), time.Since(start))
+	return err
+}
+
+// Message is a packet read from a client, still in the encoding it was sent in.
+type Message struct {
+	Data  []byte
+	Codec Codec
+}
+
+// Decode decodes the packet into v.
+func (m Message) Decode(v any) error {
+	return m.Codec.Unmarshal(m.Data, v)
+}
+
+// JSON returns the packet encoded as JSON.
+func (m Message) JSON() ([]byte, error) {
+	return m.Codec.ToJSON(m.Data)
+}
+
+// Read reads a packet from conn.
+func Read(ctx context.Context, conn *websocket.Conn) (Message, error) {
+	typ, data, err := conn.Read(ctx)
+	if err != nil {
+		return Message{}, err
+	}
+	statsFromContext(ctx).recordRead(len(data))
+	if typ == websocket.MessageBinary {
+		return Message{Data: data, Codec: Msgpack}, nil
+	}
+	return Message{Data: data, Codec: JSON}, nil
+}
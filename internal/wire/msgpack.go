@@ -0,0 +1,129 @@
+package wire
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"github.com/vmihailenco/msgpack/v5/msgpcode"
+)
+
+// Packets are encoded the same way encoding/json would encode them, so they convert to the same JSON:
+// the json struct tags are used, json.RawMessage values are encoded as the value they contain
+// and times as RFC 3339 strings instead of the MessagePack timestamp extension.
+func init() {
+	msgpack.Register(json.RawMessage{}, encodeRawMessage, decodeRawMessage)
+	msgpack.Register(time.Time{}, encodeTime, decodeTime)
+}
+
+func encodeRawMessage(enc *msgpack.Encoder, v reflect.Value) error {
+	raw := v.Bytes()
+	if len(raw) == 0 {
+		return enc.EncodeNil()
+	}
+	var value any
+	if err := json.Unmarshal(raw, &value); err != nil {
+		return err
+	}
+	return enc.Encode(value)
+}
+
+func decodeRawMessage(dec *msgpack.Decoder, v reflect.Value) error {
+	value, err := dec.DecodeInterfaceLoose()
+	if err != nil {
+		return err
+	}
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Errorf("msgpack: %w", err)
+	}
+	v.SetBytes(raw)
+	return nil
+}
+
+func encodeTime(enc *msgpack.Encoder, v reflect.Value) error {
+	return enc.EncodeString(v.Interface().(time.Time).Format(time.RFC3339Nano))
+}
+
+func decodeTime(dec *msgpack.Decoder, v reflect.Value) error {
+	code, err := dec.PeekCode()
+	if err != nil {
+		return err
+	}
+	var t time.Time
+	if msgpcode.IsString(code) {
+		s, err := dec.DecodeString()
+		if err != nil {
+			return err
+		}
+		if t, err = time.Parse(time.RFC3339, s); err != nil {
+			return err
+		}
+	} else if t, err = dec.DecodeTime(); err != nil {
+		return err
+	}
+	v.Set(reflect.ValueOf(t))
+	return nil
+}
+
+// MarshalMsgpack encodes v as MessagePack, see init for how values are encoded.
+func MarshalMsgpack(v any) ([]byte, error) {
+	enc := msgpack.GetEncoder()
+	defer msgpack.PutEncoder(enc)
+
+	var buf bytes.Buffer
+	enc.Reset(&buf)
+	enc.SetCustomStructTag("json")
+	enc.UseCompactInts(true)
+	enc.UseCompactFloats(true)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// UnmarshalMsgpack decodes MessagePack data into v, like json.Unmarshal decodes JSON.
+// Values decoded into interfaces get the types encoding/json would give them, except that
+// integers are int64 or uint64 and binary values are strings.
+func UnmarshalMsgpack(data []byte, v any) error {
+	dec := msgpack.GetDecoder()
+	defer msgpack.PutDecoder(dec)
+
+	// The decoder allocates arrays for the length they claim before reading their items,
+	// skipping the data first fails on lengths that don't fit in it without allocating.
+	r := bytes.NewReader(data)
+	dec.Reset(r)
+	if err := dec.Skip(); err != nil {
+		return err
+	}
+
+	r.Reset(data)
+	dec.Reset(r)
+	dec.SetCustomStructTag("json")
+	dec.UseLooseInterfaceDecoding(true)
+	if err := dec.Decode(v); err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return fmt.Errorf("msgpack: data after the top-level value")
+	}
+	return nil
+}
+
+// JSONToMsgpack converts a JSON document to MessagePack.
+func JSONToMsgpack(data []byte) ([]byte, error) {
+	return MarshalMsgpack(json.RawMessage(data))
+}
+
+// MsgpackToJSON converts a MessagePack document to JSON. Map keys must be strings, binary values
+// become strings and extension types aren't supported.
+func MsgpackToJSON(data []byte) ([]byte, error) {
+	var raw json.RawMessage
+	if err := UnmarshalMsgpack(data, &raw); err != nil {
+		return nil, err
+	}
+	return raw, nil
+}
@@ -0,0 +1,262 @@
+package wire
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+type embedded struct {
+	Owner   string `json:"owner"`
+	Version int    `json:"version"`
+}
+
+type testPacket struct {
+	RequestID string `json:"rid,omitempty"`
+	Type      string `json:"type"`
+
+	embedded
+	Version   string          `json:"v"`
+	Count     int             `json:"count"`
+	Negative  int64           `json:"negative"`
+	Ratio     float64         `json:"ratio"`
+	Ready     bool            `json:"ready"`
+	Peers     []string        `json:"peers"`
+	Empty     []string        `json:"empty,omitempty"`
+	Data      map[string]any  `json:"data"`
+	Raw       json.RawMessage `json:"raw"`
+	Binary    []byte          `json:"binary"`
+	At        time.Time       `json:"at"`
+	Until     *time.Time      `json:"until"`
+	Optional  *int            `json:"optional"`
+	Ignored   string          `json:"-"`
+	unexposed string
+}
+
+func samplePacket() testPacket {
+	until := time.Date(2024, 1, 2, 3, 4, 5, 6000, time.UTC)
+	return testPacket{
+		Type:     "description",
+		embedded: embedded{Owner: "peerA", Version: 3},
+		Version:  "1.2.3",
+		Count:    70000,
+		Negative: -129,
+		Ratio:    0.25,
+		Ready:    true,
+		Peers:    []string{"peerA", "peerB"},
+		Data: map[string]any{
+			"name":   "Café \"quoted\"\n",
+			"nested": map[string]any{"list": []any{1.0, "two", nil, false}},
+			"big":    float64(1 << 40),
+		},
+		Raw:       json.RawMessage(`{"sdp":"v=0\r\n","n":[1,-2,3.5]}`),
+		Binary:    []byte{0, 1, 2, 255},
+		At:        time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC),
+		Until:     &until,
+		Ignored:   "ignored",
+		unexposed: "unexposed",
+	}
+}
+
+func TestMarshalMsgpackMatchesJSON(t *testing.T) {
+	packet := samplePacket()
+	packet.Binary = nil // Binary values convert to strings instead of base64.
+
+	expected, err := json.Marshal(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := MarshalMsgpack(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	converted, err := MsgpackToJSON(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertSameJSON(t, expected, converted)
+}
+
+func TestUnmarshalMsgpack(t *testing.T) {
+	packet := samplePacket()
+	data, err := MarshalMsgpack(packet)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var decoded testPacket
+	if err := UnmarshalMsgpack(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	packet.Ignored = ""
+	packet.unexposed = ""
+
+	expected, _ := json.Marshal(packet)
+	actual, _ := json.Marshal(decoded)
+	assertSameJSON(t, expected, actual)
+	if !decoded.At.Equal(packet.At) || decoded.Until == nil || !decoded.Until.Equal(*packet.Until) {
+		t.Errorf("expected %s and %s, got %s and %v", packet.At, packet.Until, decoded.At, decoded.Until)
+	}
+}
+
+func TestUnmarshalMsgpackTypeMismatch(t *testing.T) {
+	data, err := MarshalMsgpack(map[string]any{"type": "hello", "count": "many"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded testPacket
+	if err := UnmarshalMsgpack(data, &decoded); err == nil {
+		t.Error("expected an error")
+	}
+}
+
+func TestJSONToMsgpackRoundTrip(t *testing.T) {
+	for _, doc := range []string{
+		`null`,
+		`{}`,
+		`[]`,
+		`"\u0000\u001f<>& "`,
+		`{"type":"hello","game":"9c5c9b68","since":-1,"acks":true,"n":18446744073709551615,"f":1e-7}`,
+		`[` + strings.Repeat(`{"a":[1,2,3]},`, 40) + `0]`,
+		`{"long":"` + strings.Repeat("x", 70000) + `"}`,
+	} {
+		data, err := JSONToMsgpack([]byte(doc))
+		if err != nil {
+			t.Fatalf("%s: %v", doc, err)
+		}
+		converted, err := MsgpackToJSON(data)
+		if err != nil {
+			t.Fatalf("%s: %v", doc, err)
+		}
+		assertSameJSON(t, []byte(doc), converted)
+	}
+}
+
+func TestMsgpackToJSONInvalid(t *testing.T) {
+	for name, data := range map[string][]byte{
+		"empty":          {},
+		"truncated":      {0x92, 0x01},
+		"truncated str":  {0xd9, 0x05, 'a'},
+		"huge length":    {0xdd, 0xff, 0xff, 0xff, 0xff},
+		"non-string key": {0x81, 0x01, 0x02},
+		"extension":      {0xd4, 0x01, 0x02},
+		"trailing data":  {0xc0, 0xc0},
+		"nan":            {0xcb, 0x7f, 0xf8, 0, 0, 0, 0, 0, 1},
+	} {
+		if _, err := MsgpackToJSON(data); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestMsgpackIntegers(t *testing.T) {
+	for _, n := range []int64{0, 1, 127, 128, 255, 256, 65535, 65536, 1 << 33, -1, -32, -33, -128, -129, -32768, -32769, -1 << 31, -1<<31 - 1, -1 << 62} {
+		data, err := MarshalMsgpack(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		converted, err := MsgpackToJSON(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got int64
+		if err := json.Unmarshal(converted, &got); err != nil || got != n {
+			t.Errorf("expected %d, got %s (%v)", n, converted, err)
+		}
+	}
+}
+
+func assertSameJSON(t *testing.T, expected, actual []byte) {
+	t.Helper()
+	var e, a any
+	if err := json.Unmarshal(expected, &e); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(actual, &a); err != nil {
+		t.Fatalf("invalid JSON %s: %v", actual, err)
+	}
+	eb, _ := json.Marshal(e)
+	ab, _ := json.Marshal(a)
+	if string(eb) != string(ab) {
+		t.Errorf("expected %s, got %s", eb, ab)
+	}
+}
+
+// descriptionPacket is like the description packets that make up most of the traffic when peers join a lobby.
+type descriptionPacket struct {
+	Type        string `json:"type"`
+	Source      string `json:"source"`
+	Recipient   string `json:"recipient"`
+	Description struct {
+		Type string `json:"type"`
+		SDP  string `json:"sdp"`
+	} `json:"description"`
+}
+
+func sampleDescription() descriptionPacket {
+	var p descriptionPacket
+	p.Type = "description"
+	p.Source = "cq3v8rmqf2vc73cmdsng"
+	p.Recipient = "cq3v8rmqf2vc73cmdso0"
+	p.Description.Type = "offer"
+	p.Description.SDP = strings.Repeat("a=candidate:1 1 udp 2122260223 192.168.1.10 54321 typ host generation 0\r\n", 20)
+	return p
+}
+
+func BenchmarkMarshal(b *testing.B) {
+	packet := sampleDescription()
+	for _, bc := range []struct {
+		name  string
+		codec Codec
+	}{{"json", JSON}, {"msgpack", Msgpack}} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			size := 0
+			for b.Loop() {
+				data, err := bc.codec.Marshal(packet)
+				if err != nil {
+					b.Fatal(err)
+				}
+				size = len(data)
+			}
+			b.ReportMetric(float64(size), "bytes/packet")
+		})
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	packet := sampleDescription()
+	jsonData, _ := json.Marshal(packet)
+	msgpackData, _ := MarshalMsgpack(packet)
+
+	b.Run("json", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var p descriptionPacket
+			if err := json.Unmarshal(jsonData, &p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("msgpack", func(b *testing.B) {
+		b.ReportAllocs()
+		for b.Loop() {
+			var p descriptionPacket
+			if err := UnmarshalMsgpack(msgpackData, &p); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkForward(b *testing.B) {
+	data, _ := json.Marshal(sampleDescription())
+	b.ReportAllocs()
+	for b.Loop() {
+		if _, err := JSONToMsgpack(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package wire
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"math"
+	"net"
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Stats counts the packets sent and received on a connection and the bytes that went over
+// the network for them, to see how well compression works and what it costs.
+type Stats struct {
+	// Sent and Received are the sizes of the packets before compression.
+	Sent     atomic.Int64
+	Received atomic.Int64
+
+	// SentWire and ReceivedWire are the sizes of the packets as they were written to and read
+	// from the network, after compression. The websocket handshake, frame headers and control
+	// frames aren't counted, so they are the same as Sent and Received without compression.
+	SentWire     atomic.Int64
+	ReceivedWire atomic.Int64
+
+	// Messages is the number of packets sent and WriteTime the time spent writing them,
+	// which includes compressing them.
+	Messages  atomic.Int64
+	WriteTime atomic.Int64 // in nanoseconds
+}
+
+type statsContextKey struct{}
+
+// WithStats returns a context that counts the packets written with Write and WriteJSON and read with Read in stats.
+func WithStats(ctx context.Context, stats *Stats) context.Context {
+	return context.WithValue(ctx, statsContextKey{}, stats)
+}
+
+func statsFromContext(ctx context.Context) *Stats {
+	stats, _ := ctx.Value(statsContextKey{}).(*Stats)
+	return stats
+}
+
+func (s *Stats) recordWrite(size int, d time.Duration) {
+	if s == nil {
+		return
+	}
+	s.Messages.Add(1)
+	s.Sent.Add(int64(size))
+	s.WriteTime.Add(int64(d))
+}
+
+func (s *Stats) recordRead(size int) {
+	if s == nil {
+		return
+	}
+	s.Received.Add(int64(size))
+}
+
+// ResponseWriter wraps w so the connection it's hijacked for, like by websocket.Accept,
+// counts the payload bytes of the websocket messages it writes and reads in s.
+func (s *Stats) ResponseWriter(w http.ResponseWriter) http.ResponseWriter {
+	return &countingResponseWriter{ResponseWriter: w, stats: s}
+}
+
+type countingResponseWriter struct {
+	http.ResponseWriter
+	stats *Stats
+}
+
+func (w *countingResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func (w *countingResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	conn, brw, err := http.NewResponseController(w.ResponseWriter).Hijack()
+	if err != nil {
+		return nil, nil, err
+	}
+	// The handshake response is written when flushing, before counting starts.
+	if err := brw.Writer.Flush(); err != nil {
+		return nil, nil, err
+	}
+	counting := &countingConn{Conn: conn, stats: w.stats}
+	// Frames already buffered by the reader were read from the network before the hijack, count them too.
+	buffered, _ := brw.Reader.Peek(brw.Reader.Buffered())
+	w.stats.ReceivedWire.Add(counting.read.count(buffered))
+	return counting, bufio.NewReadWriter(brw.Reader, bufio.NewWriterSize(counting, brw.Writer.Size())), nil
+}
+
+type countingConn struct {
+	net.Conn
+	stats *Stats
+
+	read, write frameCounter
+}
+
+func (c *countingConn) Read(b []byte) (int, error) {
+	n, err := c.Conn.Read(b)
+	c.stats.ReceivedWire.Add(c.read.count(b[:n]))
+	return n, err
+}
+
+func (c *countingConn) Write(b []byte) (int, error) {
+	n, err := c.Conn.Write(b)
+	c.stats.SentWire.Add(c.write.count(b[:n]))
+	return n, err
+}
+
+// frameCounter follows a stream of websocket frames to count the payload bytes of its data frames.
+// A connection is never read or written from multiple goroutines at once, so it doesn't need a lock.
+type frameCounter struct {
+	header [14]byte // The longest header: 2 bytes, an 8 byte length and a 4 byte mask.
+	n      int      // The number of bytes of the header read so far.
+
+	payload int64 // The number of bytes left of the payload of the current frame.
+	data    bool  // Whether the current frame is a data frame.
+}
+
+func (f *frameCounter) count(b []byte) int64 {
+	var total int64
+	for len(b) > 0 {
+		if f.payload > 0 {
+			n := min(int64(len(b)), f.payload)
+			if f.data {
+				total += n
+			}
+			f.payload -= n
+			b = b[n:]
+			continue
+		}
+
+		f.header[f.n] = b[0]
+		f.n++
+		b = b[1:]
+		if f.n < 2 || f.n < headerSize(f.header[:f.n]) {
+			continue
+		}
+
+		opcode := f.header[0] & 0x0f
+		f.data = opcode <= 2 // Continuation, text and binary frames.
+		switch length := f.header[1] & 0x7f; length {
+		case 126:
+			f.payload = int64(binary.BigEndian.Uint16(f.header[2:]))
+		case 127:
+			f.payload = int64(binary.BigEndian.Uint64(f.header[2:]) & math.MaxInt64)
+		default:
+			f.payload = int64(length)
+		}
+		f.n = 0
+	}
+	return total
+}
+
+// headerSize returns the size of a frame header from its first two bytes.
+func headerSize(header []byte) int {
+	size := 2
+	switch header[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+	if header[1]&0x80 != 0 { // Masked, like all frames from clients.
+		size += 4
+	}
+	return size
+}
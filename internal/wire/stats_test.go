@@ -0,0 +1,103 @@
+package wire
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/coder/websocket"
+)
+
+func exchange(t *testing.T, stats *Stats, mode websocket.CompressionMode) {
+	t.Helper()
+	packet := map[string]string{"type": "description", "sdp": strings.Repeat("a=candidate:1 1 udp 2122260223 192.168.1.10 54321 typ host\r\n", 50)}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := websocket.Accept(stats.ResponseWriter(w), r, &websocket.AcceptOptions{
+			CompressionMode: mode,
+		})
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		ctx := WithStats(r.Context(), stats)
+		if err := Write(ctx, conn, packet); err != nil {
+			t.Error(err)
+		}
+		if _, err := Read(ctx, conn); err != nil {
+			t.Error(err)
+		}
+		conn.Close(websocket.StatusNormalClosure, "") // nolint:errcheck
+	}))
+	defer server.Close()
+
+	ctx := context.Background()
+	conn, _, err := websocket.Dial(ctx, "ws"+strings.TrimPrefix(server.URL, "http"), &websocket.DialOptions{
+		CompressionMode: mode,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := conn.Read(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if err := conn.Write(ctx, websocket.MessageText, []byte(`{"type":"pong"}`)); err != nil {
+		t.Fatal(err)
+	}
+	conn.Read(ctx) // nolint:errcheck // Wait for the server to close the connection.
+}
+
+func TestStatsCountCompressedBytes(t *testing.T) {
+	stats := &Stats{}
+	exchange(t, stats, websocket.CompressionContextTakeover)
+
+	if stats.Messages.Load() != 1 || stats.Received.Load() != 15 {
+		t.Fatalf("expected 1 message sent and 15 bytes received, got %d and %d", stats.Messages.Load(), stats.Received.Load())
+	}
+	if sent, wire := stats.Sent.Load(), stats.SentWire.Load(); wire == 0 || wire >= sent {
+		t.Errorf("expected the packet of %d bytes to be sent compressed, %d bytes were written", sent, wire)
+	}
+	if stats.ReceivedWire.Load() == 0 {
+		t.Error("expected received bytes to be counted")
+	}
+}
+
+func TestStatsExcludeFraming(t *testing.T) {
+	stats := &Stats{}
+	exchange(t, stats, websocket.CompressionDisabled)
+
+	if sent, wire := stats.Sent.Load(), stats.SentWire.Load(); wire != sent {
+		t.Errorf("expected %d bytes sent without the handshake and framing, got %d", sent, wire)
+	}
+	if received, wire := stats.Received.Load(), stats.ReceivedWire.Load(); wire != received {
+		t.Errorf("expected %d bytes received without the handshake and framing, got %d", received, wire)
+	}
+}
+
+func TestFrameCounter(t *testing.T) {
+	frame := func(header []byte, size int) []byte {
+		return append(header, make([]byte, size)...)
+	}
+	var stream []byte
+	stream = append(stream, frame([]byte{0x81, 5}, 5)...)                                    // Text.
+	stream = append(stream, frame([]byte{0x82, 0x80 | 126, 0x01, 0x00, 1, 2, 3, 4}, 256)...) // Masked binary.
+	stream = append(stream, frame([]byte{0x89, 4}, 4)...)                                    // Ping.
+	stream = append(stream, frame([]byte{0x00, 127, 0, 0, 0, 0, 0, 1, 0, 0}, 65536)...)      // Continuation.
+	stream = append(stream, frame([]byte{0x88, 2}, 2)...)                                    // Close.
+
+	// Frames are counted the same no matter how the stream is split.
+	for _, size := range []int{1, 3, 4096, len(stream)} {
+		var f frameCounter
+		var total int64
+		for b := stream; len(b) > 0; {
+			n := min(size, len(b))
+			total += f.count(b[:n])
+			b = b[n:]
+		}
+		if total != 5+256+65536 {
+			t.Errorf("split in %d bytes: expected %d payload bytes, got %d", size, 5+256+65536, total)
+		}
+	}
+}
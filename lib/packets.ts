@@ -0,0 +1,217 @@
+// Code generated by packetgen from internal/signaling/schema/packets.json. DO NOT EDIT.
+
+export type ClientPacketTypes =
+| PingPacket
+| HelloPacket
+| CreateTransferTokenPacket
+| AckPacket
+| ListPacket
+| CreatePacket
+| JoinPacket
+| SetStatePacket
+| GetStatePacket
+| ReplayPacket
+| LobbyUpdatePacket
+| PeerUpdatePacket
+| ReadyPacket
+| LeavePacket
+| ClosePacket
+| ConnectedPacket
+| DisconnectedPacket
+| CandidatePacket
+| DescriptionPacket
+
+export interface PingPacket {
+  type: 'ping'
+  rid?: string
+}
+
+export interface HelloPacket {
+  type: 'hello'
+  rid?: string
+  game: string
+  id?: string
+  secret?: string
+  version?: string
+  token?: string
+  identityToken?: string
+  peerCustomData?: { [key: string]: any }
+  /**
+   * Since is the seq of the last lobby event the peer received before reconnecting, the events after it
+   * are replayed when set.
+   */
+  since?: number | null
+  /**
+   * TransferToken takes over the peer that created it, instead of using id and secret.
+   */
+  transferToken?: string
+  /**
+   * Capabilities are the protocol features the client supports, see Capabilities.
+   */
+  capabilities?: string[]
+}
+
+export interface CreateTransferTokenPacket {
+  type: 'createTransferToken'
+  rid?: string
+}
+
+export interface AckPacket {
+  type: 'ack'
+  rid?: string
+  msgSeq: number
+}
+
+export interface ListPacket {
+  type: 'list'
+  rid?: string
+  filter?: string
+  sort?: string
+  limit?: number
+  includeInGame?: boolean
+}
+
+export interface CreatePacket {
+  type: 'create'
+  rid?: string
+  code?: string
+  codeFormat?: string
+  codeLength?: number
+  public?: boolean
+  password?: string
+  maxPlayers?: number | null
+  customData?: { [key: string]: any }
+  canUpdateBy?: string
+  /**
+   * TTL is in seconds, 0 means the lobby doesn't expire.
+   */
+  ttl?: number
+  /**
+   * IdleTimeout is in seconds, 0 means the lobby doesn't expire when it's idle.
+   */
+  idleTimeout?: number
+  /**
+   * ReconnectPolicy is hold (the default) to keep the slot of a peer that lost its connection until it
+   * times out, or free to remove the peer right away.
+   */
+  reconnectPolicy?: string
+  peerCustomData?: { [key: string]: any }
+}
+
+export interface JoinPacket {
+  type: 'join'
+  rid?: string
+  lobby: string
+  password?: string
+  peerCustomData?: { [key: string]: any }
+}
+
+export interface SetStatePacket {
+  type: 'setState'
+  rid?: string
+  key?: string
+  /**
+   * Value is any JSON value, null removes the key.
+   */
+  value?: any
+  access?: string
+  expectedVersion?: number | null
+}
+
+export interface GetStatePacket {
+  type: 'getState'
+  rid?: string
+}
+
+export interface ReplayPacket {
+  type: 'replay'
+  rid?: string
+  since?: number
+}
+
+export interface LobbyUpdatePacket {
+  type: 'lobbyUpdate'
+  rid?: string
+  public?: boolean | null
+  customData?: { [key: string]: any } | null
+  canUpdateBy?: string | null
+  password?: string | null
+  maxPlayers?: number | null
+  state?: string | null
+  reconnectPolicy?: string | null
+  /**
+   * CustomDataPatch is a JSON merge patch applied to the customData.
+   */
+  customDataPatch?: { [key: string]: any }
+  /**
+   * SetCustomData sets top-level keys of the customData.
+   */
+  setCustomData?: { [key: string]: any }
+  /**
+   * UnsetCustomData removes top-level keys of the customData.
+   */
+  unsetCustomData?: string[]
+  /**
+   * ExpectedVersion makes the update fail with version-conflict when the lobby doesn't have this
+   * version.
+   */
+  expectedVersion?: number | null
+  /**
+   * ExpectedUpdatedAt makes the update fail with version-conflict when the lobby wasn't last updated at
+   * this time.
+   */
+  expectedUpdatedAt?: string | null
+}
+
+export interface PeerUpdatePacket {
+  type: 'peerUpdate'
+  rid?: string
+  customData?: { [key: string]: any }
+}
+
+export interface ReadyPacket {
+  type: 'ready'
+  rid?: string
+  ready: boolean
+}
+
+export interface LeavePacket {
+  type: 'leave'
+  rid?: string
+}
+
+export interface ClosePacket {
+  type: 'close'
+  rid?: string
+  id?: string
+  reason?: string
+}
+
+export interface ConnectedPacket {
+  type: 'connected'
+  rid?: string
+  id: string
+}
+
+export interface DisconnectedPacket {
+  type: 'disconnected'
+  rid?: string
+  id: string
+  reason: string
+}
+
+export interface CandidatePacket {
+  type: 'candidate'
+  rid?: string
+  source: string
+  recipient: string
+  candidate: RTCIceCandidate | null
+}
+
+export interface DescriptionPacket {
+  type: 'description'
+  rid?: string
+  source: string
+  recipient: string
+  description: RTCSessionDescription
+}
@@ -46,7 +46,11 @@ export default class Signaling extends EventEmitter<SignalingListeners> {
   }
 
   private connect (): WebSocket {
-    const ws = new WebSocket(this.url)
+    // The game and version are also passed in the url, so the server can use their compression settings.
+    const url = new URL(this.url)
+    url.searchParams.set('game', this.network.gameID)
+    url.searchParams.set('version', version)
+    const ws = new WebSocket(url.toString())
     const onOpen = (): void => {
       this.reconnectAttempt = 0
       this.reconnecting = false
@@ -55,7 +59,8 @@ export default class Signaling extends EventEmitter<SignalingListeners> {
         game: this.network.gameID,
         id: this.receivedID,
         secret: this.receivedSecret,
-        version
+        version,
+        capabilities: ['lobbyInfo']
       })
     }
     const onError = (e: Event): void => {
@@ -1,3 +1,6 @@
+import { ClientPacketTypes } from './packets'
+
+export * from './packets'
 
 export interface PeerConfiguration extends RTCConfiguration {
   /**
@@ -36,34 +39,19 @@ interface Base {
 }
 
 export type SignalingPacketTypes =
-| CandidatePacket
-| ClosePacket
-| ConnectedPacket
+| ClientPacketTypes
 | ConnectPacket
-| CreatePacket
 | CredentialsPacket
-| DescriptionPacket
-| DisconnectedPacket
 | DisconnectPacket
 | ErrorPacket
 | EventPacket
-| HelloPacket
 | JoinedPacket
-| JoinPacket
 | LeaderPacket
-| LobbyUpdatePacket
 | LobbyUpdatedPacket
-| LeavePacket
 | LeftPacket
-| ListPacket
 | LobbiesPacket
-| PingPacket
 | WelcomePacket
 
-export interface PingPacket extends Base {
-  type: 'ping'
-}
-
 export interface ErrorPacket extends Base {
   type: 'error'
   message: string
@@ -71,25 +59,12 @@ export interface ErrorPacket extends Base {
   code?: string
 }
 
-export interface HelloPacket extends Base {
-  type: 'hello'
-  game: string
-  id?: string
-  secret?: string
-  version?: string
-}
-
 export interface WelcomePacket extends Base {
   type: 'welcome'
   id: string
   secret: string
-}
-
-export interface ListPacket extends Base {
-  type: 'list'
-  filter?: string
-  sort?: string
-  limit?: number
+  protocolVersion?: number
+  capabilities?: string[]
 }
 
 export interface LobbiesPacket extends Base {
@@ -97,17 +72,6 @@ export interface LobbiesPacket extends Base {
   lobbies: LobbyListEntry[]
 }
 
-export interface CreatePacket extends Base {
-  type: 'create'
-  settings?: LobbySettings
-}
-
-export interface JoinPacket extends Base {
-  type: 'join'
-  lobby: string
-  password?: string
-}
-
 export interface JoinedPacket extends Base {
   type: 'joined'
   lobbyInfo: LobbyListEntry
@@ -119,33 +83,15 @@ export interface LeaderPacket extends Base {
   term: number
 }
 
-export interface LobbyUpdatePacket extends Base {
-  type: 'lobbyUpdate'
-  public?: boolean
-  customData?: { [key: string]: any }
-  canUpdateBy?: 'creator' | 'leader' | 'anyone' | 'none'
-  password?: string
-}
-
 export interface LobbyUpdatedPacket extends Base {
   type: 'lobbyUpdated'
   lobbyInfo: LobbyListEntry
 }
 
-export interface LeavePacket extends Base {
-  type: 'leave'
-}
-
 export interface LeftPacket extends Base {
   type: 'left'
 }
 
-export interface ClosePacket extends Base {
-  type: 'close'
-  id: string
-  reason: string
-}
-
 export interface ConnectPacket extends Base {
   type: 'connect'
   id: string
@@ -157,31 +103,6 @@ export interface DisconnectPacket extends Base {
   id: string
 }
 
-export interface ConnectedPacket extends Base {
-  type: 'connected'
-  id: string
-}
-
-export interface DisconnectedPacket extends Base {
-  type: 'disconnected'
-  id: string
-  reason: string
-}
-
-export interface CandidatePacket extends Base {
-  type: 'candidate'
-  source: string
-  recipient: string
-  candidate: RTCIceCandidate | null
-}
-
-export interface DescriptionPacket extends Base {
-  type: 'description'
-  source: string
-  recipient: string
-  description: RTCSessionDescription
-}
-
 export interface CredentialsPacket extends Base {
   type: 'credentials'
 
@@ -0,0 +1,9 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "peers_game_user_id";
+
+ALTER TABLE "peers"
+    DROP COLUMN IF EXISTS "user_id",
+    DROP COLUMN IF EXISTS "display_name";
+
+COMMIT;
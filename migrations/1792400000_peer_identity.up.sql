@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE "peers"
+    ADD COLUMN IF NOT EXISTS "user_id" VARCHAR(64),
+    ADD COLUMN IF NOT EXISTS "display_name" VARCHAR(64);
+
+CREATE INDEX IF NOT EXISTS "peers_game_user_id" ON "peers" ("game", "user_id") WHERE "user_id" IS NOT NULL;
+
+COMMIT;
@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE "peers"
+    ADD COLUMN IF NOT EXISTS "custom_data" JSONB;
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE "lobbies"
+    DROP COLUMN IF EXISTS "state",
+    DROP COLUMN IF EXISTS "ready";
+
+COMMIT;
@@ -0,0 +1,7 @@
+BEGIN;
+
+ALTER TABLE "lobbies"
+    ADD COLUMN IF NOT EXISTS "state" VARCHAR(16) NOT NULL DEFAULT 'waiting',
+    ADD COLUMN IF NOT EXISTS "ready" VARCHAR(20)[] NOT NULL DEFAULT '{}';
+
+COMMIT;
@@ -0,0 +1,10 @@
+BEGIN;
+
+DROP INDEX IF EXISTS "lobbies_expires_at";
+DROP INDEX IF EXISTS "lobbies_idle_timeout";
+
+ALTER TABLE "lobbies"
+    DROP COLUMN IF EXISTS "expires_at",
+    DROP COLUMN IF EXISTS "idle_timeout";
+
+COMMIT;
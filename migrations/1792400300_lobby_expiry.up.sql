@@ -0,0 +1,10 @@
+BEGIN;
+
+ALTER TABLE "lobbies"
+    ADD COLUMN IF NOT EXISTS "expires_at" TIMESTAMP,
+    ADD COLUMN IF NOT EXISTS "idle_timeout" INT;
+
+CREATE INDEX IF NOT EXISTS "lobbies_expires_at" ON "lobbies" ("expires_at") WHERE "expires_at" IS NOT NULL;
+CREATE INDEX IF NOT EXISTS "lobbies_idle_timeout" ON "lobbies" ("updated_at") WHERE "idle_timeout" IS NOT NULL;
+
+COMMIT;
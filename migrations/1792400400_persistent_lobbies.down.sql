@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE "lobbies"
+    DROP COLUMN IF EXISTS "persistent";
+
+COMMIT;
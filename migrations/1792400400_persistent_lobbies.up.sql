@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE "lobbies"
+    ADD COLUMN IF NOT EXISTS "persistent" BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
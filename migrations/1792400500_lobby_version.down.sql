@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP FUNCTION IF EXISTS jsonb_merge_patch;
+
+ALTER TABLE "lobbies"
+    DROP COLUMN IF EXISTS "version";
+
+COMMIT;
@@ -0,0 +1,39 @@
+BEGIN;
+
+ALTER TABLE "lobbies"
+    ADD COLUMN IF NOT EXISTS "version" INT NOT NULL DEFAULT 1;
+
+
+-- Applies a JSON merge patch (RFC 7396) to target: keys with a null value are removed,
+-- objects are merged recursively and all other values replace the value in target.
+CREATE OR REPLACE FUNCTION jsonb_merge_patch(
+    target jsonb,
+    patch  jsonb
+) RETURNS jsonb
+LANGUAGE plpgsql
+IMMUTABLE
+AS $$
+BEGIN
+    IF patch IS NULL OR jsonb_typeof(patch) <> 'object' THEN
+        RETURN patch;
+    END IF;
+    IF target IS NULL OR jsonb_typeof(target) <> 'object' THEN
+        target := '{}'::jsonb;
+    END IF;
+
+    RETURN (
+        SELECT COALESCE(jsonb_object_agg(merged.key, merged.value), '{}'::jsonb)
+        FROM (
+            SELECT t.key, t.value
+            FROM jsonb_each(target) t
+            WHERE NOT patch ? t.key
+            UNION ALL
+            SELECT p.key, jsonb_merge_patch(target -> p.key, p.value)
+            FROM jsonb_each(patch) p
+            WHERE jsonb_typeof(p.value) <> 'null'
+        ) merged
+    );
+END;
+$$;
+
+COMMIT;
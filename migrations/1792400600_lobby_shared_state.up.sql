@@ -0,0 +1,16 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS "lobby_shared_state" (
+  "game" uuid NOT NULL,
+  "lobby" VARCHAR(20) NOT NULL,
+  "key" VARCHAR(64) NOT NULL,
+  "value" jsonb NOT NULL,
+  "owner" VARCHAR(20) NOT NULL,
+  "access" VARCHAR(10) NOT NULL DEFAULT 'anyone',
+  "version" INT NOT NULL DEFAULT 1,
+  "updated_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("game", "lobby", "key"),
+  FOREIGN KEY ("lobby", "game") REFERENCES "lobbies" ("code", "game") ON DELETE CASCADE
+);
+
+COMMIT;
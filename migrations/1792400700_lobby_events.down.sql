@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "lobby_events";
+
+ALTER TABLE "lobbies"
+    DROP COLUMN IF EXISTS "event_seq";
+
+COMMIT;
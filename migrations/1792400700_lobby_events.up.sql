@@ -0,0 +1,16 @@
+BEGIN;
+
+ALTER TABLE "lobbies"
+    ADD COLUMN IF NOT EXISTS "event_seq" BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "lobby_events" (
+  "game" uuid NOT NULL,
+  "lobby" VARCHAR(20) NOT NULL,
+  "seq" BIGINT NOT NULL,
+  "data" jsonb NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("game", "lobby", "seq"),
+  FOREIGN KEY ("lobby", "game") REFERENCES "lobbies" ("code", "game") ON DELETE CASCADE
+);
+
+COMMIT;
@@ -0,0 +1,8 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "peer_outbox";
+
+ALTER TABLE "peers"
+    DROP COLUMN IF EXISTS "outbox_seq";
+
+COMMIT;
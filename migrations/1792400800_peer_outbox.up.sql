@@ -0,0 +1,14 @@
+BEGIN;
+
+ALTER TABLE "peers"
+    ADD COLUMN IF NOT EXISTS "outbox_seq" BIGINT NOT NULL DEFAULT 0;
+
+CREATE TABLE IF NOT EXISTS "peer_outbox" (
+  "peer" VARCHAR(20) NOT NULL REFERENCES "peers" ("peer") ON DELETE CASCADE,
+  "seq" BIGINT NOT NULL,
+  "data" jsonb NOT NULL,
+  "created_at" TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+  PRIMARY KEY ("peer", "seq")
+);
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE "peers"
+    DROP COLUMN IF EXISTS "disconnect_threshold";
+
+ALTER TABLE "lobbies"
+    DROP COLUMN IF EXISTS "reconnect_policy";
+
+COMMIT;
@@ -0,0 +1,9 @@
+BEGIN;
+
+ALTER TABLE "lobbies"
+    ADD COLUMN IF NOT EXISTS "reconnect_policy" VARCHAR(20) NOT NULL DEFAULT 'hold';
+
+ALTER TABLE "peers"
+    ADD COLUMN IF NOT EXISTS "disconnect_threshold" INTEGER;
+
+COMMIT;
@@ -0,0 +1,5 @@
+BEGIN;
+
+DROP TABLE IF EXISTS "peer_transfer_tokens";
+
+COMMIT;
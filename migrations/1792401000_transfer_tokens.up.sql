@@ -0,0 +1,11 @@
+BEGIN;
+
+CREATE TABLE IF NOT EXISTS "peer_transfer_tokens" (
+  "token" VARCHAR(64) NOT NULL PRIMARY KEY,
+  "peer" VARCHAR(20) NOT NULL REFERENCES "peers" ("peer") ON DELETE CASCADE,
+  "expires_at" TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS "peer_transfer_tokens_expires_at" ON "peer_transfer_tokens" ("expires_at");
+
+COMMIT;
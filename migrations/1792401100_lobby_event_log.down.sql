@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE "lobbies"
+    DROP COLUMN IF EXISTS "event_log";
+
+COMMIT;
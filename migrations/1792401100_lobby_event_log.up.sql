@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE "lobbies"
+    ADD COLUMN IF NOT EXISTS "event_log" BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;
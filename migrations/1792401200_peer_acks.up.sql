@@ -0,0 +1,6 @@
+BEGIN;
+
+ALTER TABLE "peers"
+    ADD COLUMN IF NOT EXISTS "acks" BOOLEAN NOT NULL DEFAULT FALSE;
+
+COMMIT;